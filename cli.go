@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/PusztaiMate/ingatlan-crawler/crawlers"
+)
+
+// commonFlags are the flags shared by every command that works with a config file.
+type commonFlags struct {
+	configPath string
+	portals    string
+	storePath  string
+	verbose    bool
+	quiet      bool
+}
+
+func (cf *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.configPath, "config", "config.json", "path of the config file (.json, .yaml or .toml), INGATLAN_* environment variables override its values")
+	fs.StringVar(&cf.portals, "portals", "", "comma separated list of portals to use, overrides the config")
+	fs.StringVar(&cf.storePath, "store", "", "path of the listing store, overrides the config")
+	fs.BoolVar(&cf.verbose, "v", false, "verbose logging: the config used, every request and every wait of the rate limits")
+	fs.BoolVar(&cf.quiet, "q", false, "suppress progress logging")
+}
+
+// setupLogging applies the verbosity flags. Quiet wins over verbose.
+func (cf *commonFlags) setupLogging() {
+	if cf.quiet {
+		log.SetOutput(ioutil.Discard)
+		return
+	}
+	crawlers.Verbose = cf.verbose
+}
+
+// loadConfig reads the config, applies the flag overrides and validates the result.
+func (cf *commonFlags) loadConfig() (crawlers.Config, error) {
+	config, err := crawlers.LoadConfig(cf.configPath)
+	if err != nil {
+		return crawlers.Config{}, err
+	}
+
+	if len(cf.portals) != 0 {
+		config.Portals = splitList(cf.portals)
+		for i := range config.Searches {
+			config.Searches[i].Portals = config.Portals
+		}
+	}
+	if len(cf.storePath) != 0 {
+		config.StorePath = cf.storePath
+	}
+	if err := config.Validate(); err != nil {
+		return crawlers.Config{}, fmt.Errorf("%s: %w", cf.configPath, err)
+	}
+	if cf.verbose {
+		log.Printf("Config used: %#v", config)
+	}
+
+	return config, nil
+}
+
+// csvConfig returns the csv settings of the config, or the defaults when
+// there is no config file.
+func (cf *commonFlags) csvConfig() (crawlers.CsvConfig, error) {
+	if _, err := os.Stat(cf.configPath); os.IsNotExist(err) {
+		return crawlers.CsvConfig{}, nil
+	}
+	config, err := cf.loadConfig()
+	if err != nil {
+		return crawlers.CsvConfig{}, err
+	}
+	return config.Csv, nil
+}
+
+// openStore opens the store given by the flags or the config.
+func (cf *commonFlags) openStore() (crawlers.Store, error) {
+	storePath := cf.storePath
+	if len(storePath) == 0 {
+		config, err := cf.loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		storePath = config.StorePath
+	}
+	if len(storePath) == 0 {
+		return nil, errors.New("no store given, use -store or set 'adatbázis' in the config")
+	}
+
+	return crawlers.OpenJsonFileStore(storePath)
+}
+
+// loadStoredListings reads every listing from the store given by the flags or the config.
+func (cf *commonFlags) loadStoredListings() ([]crawlers.StoredListing, error) {
+	store, err := cf.openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return store.Listings()
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) != 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PusztaiMate/ingatlan-crawler/crawlers"
+)
+
+func runValidateConfigCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	var cf commonFlags
+	cf.register(fs)
+	fs.Parse(args)
+
+	cf.setupLogging()
+	config, err := cf.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: ok, %d search(es)\n", cf.configPath, len(config.AllSearches()))
+	return nil
+}
+
+func runListPortalsCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list-portals", flag.ExitOnError)
+	fs.Parse(args)
+
+	for _, name := range crawlers.PortalNames() {
+		p, _ := crawlers.GetPortal(name)
+		fmt.Printf("%-16s %s\n", name, p.BaseUrl())
+	}
+	return nil
+}
+
+func runExtractionRulesCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("extraction-rules", flag.ExitOnError)
+	fs.Parse(args)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false) // keep the '>' of the selectors readable
+	return enc.Encode(crawlers.DefaultExtractionRules())
+}
+
+func runExportCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var cf commonFlags
+	cf.register(fs)
+	output := fs.String("output", "-", "path of the file to write, '-' for stdout")
+	format := fs.String("format", "csv", "output format: "+strings.Join(crawlers.OutputFormats(), ", "))
+	seenWithin := fs.Duration("seen-within", 0, "only export listings seen in this period, e.g. 48h (default: all)")
+	fs.Parse(args)
+
+	cf.setupLogging()
+	csvConfig, err := cf.csvConfig()
+	if err != nil {
+		return err
+	}
+	writers, err := crawlers.OutputWriters([]string{*format}, csvConfig)
+	if err != nil {
+		return err
+	}
+	ow := writers[0]
+	listings, err := cf.loadStoredListings()
+	if err != nil {
+		return err
+	}
+
+	var props []crawlers.PropertyInfo
+	for _, l := range listings {
+		if *seenWithin > 0 && time.Since(l.LastSeen) > *seenWithin {
+			continue
+		}
+		props = append(props, l.Latest())
+	}
+
+	groups := crawlers.GroupsOfListings(props)
+	if *output == "-" {
+		return ow.Write(os.Stdout, groups)
+	}
+	if err := crawlers.WriteOutputFile(*output, ow, groups); err != nil {
+		return err
+	}
+	log.Printf("Exported %d properties to '%s'", len(props), *output)
+	return nil
+}
+
+func runPriceChangesCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("price-changes", flag.ExitOnError)
+	var cf commonFlags
+	cf.register(fs)
+	output := fs.String("output", "-", "path of the csv to write, '-' for stdout")
+	within := fs.Duration("within", 0, "only list price changes from this period, e.g. 168h (default: all)")
+	fs.Parse(args)
+
+	cf.setupLogging()
+	listings, err := cf.loadStoredListings()
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if *within > 0 {
+		since = time.Now().Add(-*within)
+	}
+	changes := crawlers.PriceChanges(listings, since)
+
+	if *output == "-" {
+		return crawlers.WritePriceChangesAsCsv(os.Stdout, changes)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := crawlers.WritePriceChangesAsCsv(f, changes); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d price change(s) to '%s'", len(changes), *output)
+	return f.Close()
+}
+
+func runDiffCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var cf commonFlags
+	cf.register(fs)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: diff [flags] [old.csv new.csv]\n\n")
+		fmt.Fprintf(fs.Output(), "Compares two csv outputs of crawl, or the last two runs in the store when no files are given.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format: '%s'", *format)
+	}
+
+	cf.setupLogging()
+	var cs crawlers.ChangeSet
+	switch fs.NArg() {
+	case 0:
+		store, err := cf.openStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		cs, err = crawlers.DiffLastRuns(store)
+		if err != nil {
+			return err
+		}
+	case 2:
+		old, err := readPropertiesCsv(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		current, err := readPropertiesCsv(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		cs = crawlers.DiffProperties(old, current)
+	default:
+		fs.Usage()
+		return errors.New("expected either no arguments or two csv files")
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cs)
+	}
+	return cs.WriteText(os.Stdout)
+}
+
+func readPropertiesCsv(path string) ([]crawlers.PropertyInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	props, err := crawlers.ReadPropertiesFromCsv(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read '%s': %s", path, err)
+	}
+	return props, nil
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PusztaiMate/ingatlan-crawler/crawlers"
+)
+
+func runCrawlCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	var cf commonFlags
+	cf.register(fs)
+	output := fs.String("output", "", "path of the output to write, '-' for stdout; with several searches it must contain "+searchPlaceholder+" (default: derived from the searches)")
+	formats := fs.String("format", "", "comma separated output formats ("+strings.Join(crawlers.OutputFormats(), ", ")+"), overrides the config; stdout takes one")
+	searchNames := fs.String("searches", "", "comma separated names of the searches to run (default: all)")
+	record := fs.String("record", "", "save every http response into this directory")
+	replay := fs.String("replay", "", "serve the http responses saved by -record from this directory instead of the network")
+	fs.Parse(args)
+
+	cf.setupLogging()
+	config, err := cf.loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(*record) != 0 && len(*replay) != 0 {
+		return errors.New("-record and -replay exclude each other")
+	}
+	if len(*record) != 0 {
+		config.Http.FixtureMode, config.Http.FixturesDir = crawlers.FixtureRecord, *record
+	}
+	if len(*replay) != 0 {
+		config.Http.FixtureMode, config.Http.FixturesDir = crawlers.FixtureReplay, *replay
+	}
+	searches, err := selectSearches(config, splitList(*searchNames))
+	if err != nil {
+		return err
+	}
+	if len(searches) > 1 && *output != "-" && len(*output) != 0 && !strings.Contains(*output, searchPlaceholder) {
+		return fmt.Errorf("-output must contain %s when running %d searches", searchPlaceholder, len(searches))
+	}
+	writersOf, err := outputWriters(searches, splitList(*formats), *output == "-", config.Csv)
+	if err != nil {
+		return err
+	}
+
+	report := &crawlers.FailureReport{}
+	stats := crawlers.NewExtractionStats()
+	startedAt := time.Now()
+	props, failedKeys, crawlErr := crawl(ctx, config, searches, report, stats)
+	if crawlErr != nil && !isInterrupted(crawlErr) {
+		return crawlErr
+	}
+	if crawlErr != nil {
+		log.Printf("crawl interrupted, saving the %d properties collected so far", len(props))
+	}
+
+	fillRates := stats.FillRates()
+	previousFillRates, err := loadPreviousFillRates(config.StorePath, namesOf(searches))
+	if err != nil {
+		log.Printf("could not read the previous runs from store '%s', checking the fill rates against the limits only: %s", config.StorePath, err)
+	}
+	healthErr := crawlers.CheckHealth(fillRates, previousFillRates, config.Health)
+	if healthErr != nil {
+		log.Println(healthErr)
+	}
+
+	// a failing store must not cost the results, they are still written to the outputs
+	var priceChanges []crawlers.PriceChange
+	var storeErr error
+	if len(config.StorePath) != 0 && healthErr != nil {
+		log.Printf("Not saving to store '%s', the listings of a broken extraction would spoil the history", config.StorePath)
+	} else if len(config.StorePath) != 0 {
+		run := crawlers.CrawlRun{StartedAt: startedAt, Interrupted: crawlErr != nil, Searches: namesOf(searches), FailedKeys: failedKeys, FillRates: fillRates}
+		priceChanges, err = saveToStore(config.StorePath, props, run)
+		if err != nil {
+			storeErr = fmt.Errorf("could not save properties to store '%s': %s", config.StorePath, err)
+			log.Println(storeErr)
+		}
+	}
+
+	// the store gets every listing for tracking the market, the output only the interesting ones
+	var allGroups []crawlers.PropertyGroup
+	var filenames []string
+	for _, search := range searches {
+		searchProps, filterReport := crawlers.FilterProperties(propsOfSearch(props, search.Name), search.Filter)
+		log.Printf("Filtering '%s': %s", search.Name, filterReport)
+
+		groups := crawlers.GroupDuplicates(searchProps, config.Matching)
+		if len(groups) < len(searchProps) {
+			log.Printf("%d listing(s) of '%s' turned out to be the same as another portal's, %d unique properties", len(searchProps)-len(groups), search.Name, len(groups))
+		}
+
+		if *output == "-" {
+			allGroups = append(allGroups, groups...)
+			continue
+		}
+
+		for _, ow := range writersOf[search.Name] {
+			filename := crawlers.OutputFileName(outputFileName(*output, search, len(searches) > 1), ow)
+			log.Printf("Collection finished, writing data of '%s' to '%s'", search.Name, filename)
+			if err := crawlers.WriteOutputFile(filename, ow, groups); err != nil {
+				return err
+			}
+			filenames = append(filenames, filename)
+		}
+	}
+
+	if *output == "-" {
+		if err := writersOf[searches[0].Name][0].Write(os.Stdout, allGroups); err != nil {
+			return err
+		}
+		if report.Len() != 0 {
+			log.Printf("%d page(s) failed (%s)", report.Len(), report.Summary())
+		}
+		return firstError(crawlErr, healthErr, storeErr)
+	}
+
+	// the reports cover the whole run, they go next to the first output
+	if err := writeFailureReport(report, filenames[0]); err != nil {
+		return err
+	}
+	if err := writePriceChanges(priceChanges, filenames[0]); err != nil {
+		return err
+	}
+	if err := writeFillRates(fillRates, previousFillRates, filenames[0]); err != nil {
+		return err
+	}
+	log.Println("Finished!")
+	return firstError(crawlErr, healthErr, storeErr)
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const searchPlaceholder = "{search}"
+
+func outputFileName(output string, search crawlers.Search, prefixWithName bool) string {
+	if len(output) != 0 {
+		return strings.Replace(output, searchPlaceholder, search.Name, -1)
+	}
+	if prefixWithName {
+		return crawlers.CreateFileNameFromSearch(search, search.Name)
+	}
+	return crawlers.CreateFileNameFromSearch(search, "")
+}
+
+// selectSearches returns the searches of the config with the given names, or all of them.
+func selectSearches(config crawlers.Config, names []string) ([]crawlers.Search, error) {
+	all := config.AllSearches()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	var selected []crawlers.Search
+	for _, name := range names {
+		found := false
+		for _, s := range all {
+			if s.Name == name {
+				selected = append(selected, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no search named '%s' in the config", name)
+		}
+	}
+	return selected, nil
+}
+
+func namesOf(searches []crawlers.Search) []string {
+	names := make([]string, len(searches))
+	for i, s := range searches {
+		names[i] = s.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func propsOfSearch(props []crawlers.PropertyInfo, name string) []crawlers.PropertyInfo {
+	var filtered []crawlers.PropertyInfo
+	for _, p := range props {
+		if p.Search == name {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// outputWriters returns the writers of every search by its name. The formats
+// given on the command line win over the ones of the searches.
+func outputWriters(searches []crawlers.Search, formats []string, toStdout bool, cc crawlers.CsvConfig) (map[string][]crawlers.OutputWriter, error) {
+	if toStdout && len(formats) > 1 {
+		return nil, errors.New("-output - takes a single -format")
+	}
+
+	writers := make(map[string][]crawlers.OutputWriter)
+	for _, search := range searches {
+		searchFormats := search.Outputs
+		if len(formats) != 0 || toStdout {
+			searchFormats = formats // stdout defaults to csv, whatever the searches say
+		}
+		ow, err := crawlers.OutputWriters(searchFormats, cc)
+		if err != nil {
+			return nil, err
+		}
+		writers[search.Name] = ow
+	}
+	return writers, nil
+}
+
+// saveToStore upserts the properties, records the run and returns the
+// price changes detected in this run.
+func saveToStore(path string, props []crawlers.PropertyInfo, run crawlers.CrawlRun) ([]crawlers.PriceChange, error) {
+	store, err := crawlers.OpenJsonFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range props {
+		if err := store.Upsert(p.Key(), p, run.StartedAt); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	for _, p := range crawlers.UniqueProperties(props) {
+		run.Keys = append(run.Keys, p.Key())
+	}
+	if err := store.AddRun(run); err != nil {
+		store.Close()
+		return nil, err
+	}
+	log.Printf("Saved %d properties to store '%s'", len(props), path)
+
+	listings, err := store.Listings()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return crawlers.PriceChanges(listings, run.StartedAt), store.Close()
+}
+
+func writePriceChanges(changes []crawlers.PriceChange, output string) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	filename := strings.TrimSuffix(output, filepath.Ext(output)) + "_arvaltozasok.csv"
+	log.Printf("%d listing(s) changed their price, writing them to '%s'", len(changes), filename)
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := crawlers.WritePriceChangesAsCsv(f, changes); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// loadPreviousFillRates returns the fill rates of the latest runs of the
+// searches in the store, nothing when no store is used.
+func loadPreviousFillRates(path string, searches []string) (map[string]crawlers.FillRates, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	store, err := crawlers.OpenJsonFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	runs, err := store.Runs()
+	if err != nil {
+		return nil, err
+	}
+	return crawlers.PreviousFillRates(runs, searches), nil
+}
+
+func writeFillRates(current, previous map[string]crawlers.FillRates, output string) error {
+	if len(current) == 0 {
+		return nil
+	}
+
+	filename := strings.TrimSuffix(output, filepath.Ext(output)) + "_kitoltottseg.csv"
+	log.Printf("Writing the fill rates of the fields to '%s'", filename)
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := crawlers.WriteFillRatesAsCsv(f, current, previous); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func failureReportFileName(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + "_hibak.csv"
+}
+
+func writeFailureReport(report *crawlers.FailureReport, output string) error {
+	if report.Len() == 0 {
+		return nil
+	}
+
+	filename := failureReportFileName(output)
+	log.Printf("%d page(s) failed (%s), writing report to '%s'", report.Len(), report.Summary(), filename)
+	return report.WriteCsv(filename)
+}
+
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// useExtractionRules switches the portals listed in the rules file at path
+// to the rules, nothing happens without a path.
+func useExtractionRules(path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	rules, err := crawlers.LoadExtractionRules(path)
+	if err != nil {
+		return err
+	}
+	for portal, r := range rules {
+		if err := crawlers.SetExtractionRules(portal, r); err != nil {
+			return err
+		}
+		log.Printf("Extracting the pages of %s with the %d rule(s) of '%s'", portal, len(r), path)
+	}
+	return nil
+}
+
+// listingToFetch is a listing found by one or more searches, it is only fetched once.
+type listingToFetch struct {
+	portal   crawlers.Portal
+	link     string
+	searches []string
+}
+
+// crawl runs the searches on their enabled portals and returns the
+// properties tagged with the search that found them; a listing found by
+// several searches is returned once for each. When ctx is cancelled it
+// stops early and returns what was collected so far along with the
+// context's error. Pages that could not be processed are recorded in report
+// and their keys are returned, every extracted listing is counted in stats.
+func crawl(ctx context.Context, config crawlers.Config, searches []crawlers.Search, report *crawlers.FailureReport, stats *crawlers.ExtractionStats) ([]crawlers.PropertyInfo, []crawlers.ListingKey, error) {
+	crawlers.DefaultFetcher = crawlers.NewFetcher(config.Http)
+	if err := crawlers.SetPortalBaseUrls(config.BaseUrls); err != nil {
+		return nil, nil, err
+	}
+	if err := useExtractionRules(config.ExtractionRules); err != nil {
+		return nil, nil, err
+	}
+
+	var listings []*listingToFetch
+	listingsByKey := make(map[crawlers.ListingKey]*listingToFetch)
+	numOfResults := 0
+	for _, search := range searches {
+		portals, err := crawlers.EnabledPortals(search)
+		if err != nil {
+			return nil, nil, fmt.Errorf("search '%s': %s", search.Name, err)
+		}
+
+		for _, portal := range portals {
+			if ctx.Err() != nil {
+				break
+			}
+
+			le := portal.NewLinkCollector()
+			lpe := portal.NewListingPagesExtractor()
+			err := crawlers.CollectPropertyLinksForQuery(ctx, portal.QueryUrl(search), le, lpe)
+			if err != nil && !isInterrupted(err) {
+				log.Printf("could not collect links of '%s' from %s: %s\n", search.Name, portal.Name(), err)
+				report.Add(err)
+			}
+
+			links := crawlers.UniqueListingLinks(portal, le.GetLinks())
+			log.Printf("Collected (%d) links of '%s' from %s", len(links), search.Name, portal.Name())
+			for _, link := range links {
+				key := crawlers.ListingKeyForLink(portal, link)
+				l, ok := listingsByKey[key]
+				if !ok {
+					l = &listingToFetch{portal: portal, link: link}
+					listingsByKey[key] = l
+					listings = append(listings, l)
+				}
+				l.searches = append(l.searches, search.Name)
+				numOfResults++
+			}
+		}
+	}
+
+	scheduler := crawlers.NewScheduler(config.Workers)
+	for name, rl := range config.RateLimits {
+		portal, err := crawlers.GetPortal(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		scheduler.SetHostLimit(portal.BaseUrl(), rl)
+	}
+
+	propInfos := make(chan crawlers.PropertyInfo, numOfResults)
+	failed := make(chan crawlers.ListingKey, len(listings))
+	var tasks []crawlers.Task
+	for _, l := range listings {
+		l := l
+		tasks = append(tasks, crawlers.Task{
+			Url: crawlers.AbsolutePortalUrl(l.portal, l.link),
+			Run: func(ctx context.Context) {
+				prop, err := crawlers.CollectPropertyFromPortal(ctx, l.portal, l.link)
+				var crawlErr *crawlers.CrawlError
+				if err == nil || crawlers.IsIncomplete(err) || (errors.As(err, &crawlErr) && crawlErr.Kind == crawlers.MissingFieldErrorKind) {
+					stats.Add(prop)
+				}
+				if crawlers.IsIncomplete(err) {
+					report.Add(err)
+				} else if err != nil {
+					if !isInterrupted(err) {
+						report.Add(err)
+					}
+					failed <- crawlers.ListingKeyForLink(l.portal, l.link)
+					return
+				}
+				for _, search := range l.searches {
+					prop.Search = search
+					propInfos <- prop
+				}
+			},
+		})
+	}
+
+	log.Println("Waiting for crawlers to finish collecting info from individual pages.")
+	runErr := scheduler.Run(ctx, tasks)
+	close(propInfos)
+	close(failed)
+
+	var props []crawlers.PropertyInfo
+	for pi := range propInfos {
+		props = append(props, pi)
+	}
+	var failedKeys []crawlers.ListingKey
+	for key := range failed {
+		failedKeys = append(failedKeys, key)
+	}
+	log.Println("Finished waiting, starting processing data")
+
+	return props, failedKeys, runErr
+}
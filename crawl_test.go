@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PusztaiMate/ingatlan-crawler/crawlers"
+	"github.com/PusztaiMate/ingatlan-crawler/crawlers/fakeportal"
+)
+
+// TestCrawlAgainstFakePortals runs the crawl command end to end against a
+// fake server of every portal.
+func TestCrawlAgainstFakePortals(t *testing.T) {
+	var ingatlanListings []fakeportal.Listing
+	for i := 0; i < 45; i++ {
+		ingatlanListings = append(ingatlanListings, fakeportal.Listing{
+			Id:      fmt.Sprint(32100000 + i),
+			Address: fmt.Sprintf("Budapest XI. kerület, Bartók Béla út %d.", i+1),
+			Price:   60 + float64(i)/2,
+			Area:    90 + i,
+			Rooms:   4,
+		})
+	}
+	ingatlanListings[7].Status = 404
+	ingatlanListings[12].Delay = 200 * time.Millisecond  // slow, but in time
+	ingatlanListings[30].Delay = 1500 * time.Millisecond // slower than the timeout
+	ingatlanListings[40].Area = 0                        // a plot, kept without an area
+
+	var dunaHouseListings []fakeportal.Listing
+	for i := 0; i < 5; i++ {
+		dunaHouseListings = append(dunaHouseListings, fakeportal.Listing{
+			Id:      fmt.Sprintf("H%d", 500000+i),
+			Address: fmt.Sprintf("Budapest XI. kerület, Sasadi út %d.", 100+i),
+			Price:   80.5 + float64(i),
+			Area:    200 + i,
+			Rooms:   5,
+		})
+	}
+
+	ingatlan := fakeportal.New(fakeportal.Options{Portal: fakeportal.IngatlanCom, Listings: ingatlanListings, Throttle: 1})
+	defer ingatlan.Close()
+	dunaHouse := fakeportal.New(fakeportal.Options{Portal: fakeportal.DunaHouse, Listings: dunaHouseListings, PerPage: 2})
+	defer dunaHouse.Close()
+	if ingatlan.Pages() != 3 || dunaHouse.Pages() != 3 {
+		t.Fatalf("expected 3 listing pages on both portals, got %d and %d", ingatlan.Pages(), dunaHouse.Pages())
+	}
+
+	dir := t.TempDir()
+	configPath := writeTestConfig(t, dir, map[string]interface{}{
+		"név":            "teszt",
+		"kerületek":      []string{"xi"},
+		"min_ár":         60,
+		"max_ár":         90,
+		"min_méret":      85,
+		"max_méret":      250,
+		"lakás_vagy_ház": "haz",
+		"http":           map[string]interface{}{"időkorlát_mp": 1, "max_újrapróbálkozás": 1},
+		"sebességkorlátok": map[string]interface{}{
+			fakeportal.IngatlanCom: map[string]interface{}{"kérés_per_mp": 100, "max_párhuzamos": 8},
+			fakeportal.DunaHouse:   map[string]interface{}{"kérés_per_mp": 2, "max_párhuzamos": 1},
+		},
+		"portál_címek": map[string]string{
+			fakeportal.IngatlanCom: ingatlan.BaseUrl(),
+			fakeportal.DunaHouse:   dunaHouse.BaseUrl(),
+		},
+	})
+	restoreGlobals(t)
+
+	output := filepath.Join(dir, "eredmeny.csv")
+	if err := runCrawlCommand(context.Background(), []string{"-config", configPath, "-output", output, "-q"}); err != nil {
+		t.Fatal(err)
+	}
+
+	props := readOutput(t, output)
+	if expected := len(ingatlanListings) - 2 + len(dunaHouseListings); len(props) != expected {
+		t.Errorf("expected %d properties, got %d", expected, len(props))
+	}
+	byPortal := make(map[string]int)
+	for _, p := range props {
+		byPortal[p.Portal]++
+		if p.Price <= 0 || (p.HouseArea <= 0 && !strings.HasSuffix(p.Link, ingatlan.Link(ingatlanListings[40]))) || len(p.Address) == 0 {
+			t.Errorf("incomplete property: %+v", p)
+		}
+	}
+	if byPortal[fakeportal.DunaHouse] != len(dunaHouseListings) {
+		t.Errorf("expected every listing of dunahouse, got %d", byPortal[fakeportal.DunaHouse])
+	}
+
+	failures := readCsvRecords(t, failureReportFileName(output))
+	if len(failures) != 4 { // header, the 404, the timeout and the plot without an area
+		t.Errorf("expected 3 failures in the report, got:\n%v", failures)
+	}
+	for _, link := range []string{ingatlan.Link(ingatlanListings[7]), ingatlan.Link(ingatlanListings[30]), ingatlan.Link(ingatlanListings[40])} {
+		if !reportMentions(failures, link) {
+			t.Errorf("failure of '%s' is missing from the report:\n%v", link, failures)
+		}
+	}
+
+	// throttled detail pages are retried, every one of them got a second request
+	for _, l := range ingatlanListings {
+		if l.Status == 0 && l.Delay == 0 && requestsOf(ingatlan, ingatlan.Link(l)) != 2 {
+			t.Errorf("expected a throttled and a retried request of '%s', got %d", ingatlan.Link(l), requestsOf(ingatlan, ingatlan.Link(l)))
+		}
+	}
+
+	// dunahouse allows a burst of 2 requests, then one every 500ms
+	var details []fakeportal.Request
+	for _, r := range dunaHouse.Requests() {
+		if !strings.Contains(r.Path, "/-/") { // listing pages are fetched before the scheduler starts
+			details = append(details, r)
+		}
+	}
+	if len(details) != len(dunaHouseListings) {
+		t.Fatalf("expected a request of every dunahouse listing, got %d", len(details))
+	}
+	minimum := time.Duration(len(details)-2) * 500 * time.Millisecond
+	if took := details[len(details)-1].At.Sub(details[0].At); took < minimum*9/10 {
+		t.Errorf("dunahouse got %d requests in %s, the rate limit allows them in %s at the earliest", len(details), took, minimum)
+	}
+}
+
+func writeTestConfig(t *testing.T, dir string, config map[string]interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// restoreGlobals undoes what the crawl command changes in the package state.
+func restoreGlobals(t *testing.T) {
+	fetcher := crawlers.DefaultFetcher
+	baseUrls := make(map[string]string)
+	for _, name := range crawlers.PortalNames() {
+		p, _ := crawlers.GetPortal(name)
+		baseUrls[name] = p.BaseUrl()
+	}
+
+	t.Cleanup(func() {
+		crawlers.DefaultFetcher = fetcher
+		if err := crawlers.SetPortalBaseUrls(baseUrls); err != nil {
+			t.Error(err)
+		}
+		crawlers.ResetExtractionRules()
+		log.SetOutput(os.Stderr)
+	})
+}
+
+func readOutput(t *testing.T, path string) []crawlers.PropertyInfo {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	props, err := crawlers.ReadPropertiesFromCsv(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return props
+}
+
+func readCsvRecords(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func reportMentions(records [][]string, link string) bool {
+	for _, r := range records {
+		for _, field := range r {
+			if strings.HasSuffix(field, link) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func requestsOf(s *fakeportal.Server, path string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// TestCrawlWritesOutputsWhenTheStoreIsBroken checks that a store that can be
+// neither read nor written does not cost the results of the crawl.
+func TestCrawlWritesOutputsWhenTheStoreIsBroken(t *testing.T) {
+	var listings []fakeportal.Listing
+	for i := 0; i < 3; i++ {
+		listings = append(listings, fakeportal.Listing{
+			Id:      fmt.Sprint(32200000 + i),
+			Address: fmt.Sprintf("Budapest XI. kerület, Villányi út %d.", i+1),
+			Price:   70,
+			Area:    100,
+			Rooms:   4,
+		})
+	}
+	ingatlan := fakeportal.New(fakeportal.Options{Portal: fakeportal.IngatlanCom, Listings: listings})
+	defer ingatlan.Close()
+
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "adatbazis.json")
+	if err := ioutil.WriteFile(storePath, []byte(`{"runs": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := writeTestConfig(t, dir, map[string]interface{}{
+		"kerületek":        []string{"xi"},
+		"min_ár":           60,
+		"max_ár":           90,
+		"lakás_vagy_ház":   "haz",
+		"portálok":         []string{fakeportal.IngatlanCom},
+		"adatbázis":        storePath,
+		"sebességkorlátok": map[string]interface{}{fakeportal.IngatlanCom: map[string]interface{}{"kérés_per_mp": 100}},
+		"portál_címek":     map[string]string{fakeportal.IngatlanCom: ingatlan.BaseUrl()},
+	})
+	restoreGlobals(t)
+
+	output := filepath.Join(dir, "eredmeny.csv")
+	err := runCrawlCommand(context.Background(), []string{"-config", configPath, "-output", output, "-q"})
+	if err == nil || !strings.Contains(err.Error(), storePath) {
+		t.Errorf("expected the store error to be returned, got %v", err)
+	}
+	if props := readOutput(t, output); len(props) != len(listings) {
+		t.Errorf("expected %d properties in the output, got %d", len(listings), len(props))
+	}
+}
+
+// TestCrawlFailsWhenTheLayoutChanged checks that a run whose listings lack
+// the fields every listing should have fails and is kept out of the store.
+func TestCrawlFailsWhenTheLayoutChanged(t *testing.T) {
+	var listings []fakeportal.Listing
+	for i := 0; i < 6; i++ {
+		listings = append(listings, fakeportal.Listing{
+			Id:      fmt.Sprint(32300000 + i),
+			Address: fmt.Sprintf("Budapest XI. kerület, Bocskai út %d.", i+1),
+			Price:   70,
+			Rooms:   4, // the area is no longer found
+		})
+	}
+	ingatlan := fakeportal.New(fakeportal.Options{Portal: fakeportal.IngatlanCom, Listings: listings})
+	defer ingatlan.Close()
+
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "adatbazis.json")
+	configPath := writeTestConfig(t, dir, map[string]interface{}{
+		"kerületek":        []string{"xi"},
+		"min_ár":           60,
+		"max_ár":           90,
+		"lakás_vagy_ház":   "haz",
+		"portálok":         []string{fakeportal.IngatlanCom},
+		"adatbázis":        storePath,
+		"sebességkorlátok": map[string]interface{}{fakeportal.IngatlanCom: map[string]interface{}{"kérés_per_mp": 100}},
+		"portál_címek":     map[string]string{fakeportal.IngatlanCom: ingatlan.BaseUrl()},
+	})
+	restoreGlobals(t)
+
+	output := filepath.Join(dir, "eredmeny.csv")
+	err := runCrawlCommand(context.Background(), []string{"-config", configPath, "-output", output, "-q"})
+	var layoutErr *crawlers.LayoutChangedError
+	if !errors.As(err, &layoutErr) {
+		t.Fatalf("expected a *LayoutChangedError, got %v", err)
+	}
+	if len(layoutErr.Problems) != 1 || layoutErr.Problems[0].Field != "house_area" {
+		t.Errorf("expected only the missing areas to be reported, got %+v", layoutErr.Problems)
+	}
+	if _, err := os.Stat(storePath); !os.IsNotExist(err) {
+		t.Errorf("expected the run not to be saved to the store, stat gave %v", err)
+	}
+	if props := readOutput(t, output); len(props) != len(listings) {
+		t.Errorf("expected %d properties in the output, got %d", len(listings), len(props))
+	}
+}
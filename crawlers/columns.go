@@ -0,0 +1,189 @@
+package crawlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column is a single column of the tabular outputs. Every header and value
+// of the csv and xlsx files comes from the columns table.
+type Column struct {
+	Key     string // used in the config to select the column
+	Hu, En  string // headers
+	Numeric bool
+
+	// get returns the value with '.' as decimal separator. set is nil for
+	// the columns describing a group rather than a single listing.
+	get func(g PropertyGroup) string
+	set func(p *PropertyInfo, value string) error
+}
+
+// Header returns the header in the given language, "hu" or "en".
+func (c Column) Header(language string) string {
+	if language == "en" {
+		return c.En
+	}
+	return c.Hu
+}
+
+func textColumn(key, hu, en string, field func(p *PropertyInfo) *string) Column {
+	return Column{Key: key, Hu: hu, En: en,
+		get: func(g PropertyGroup) string { return *field(&g.Canonical) },
+		set: func(p *PropertyInfo, v string) error { *field(p) = v; return nil },
+	}
+}
+
+func intColumn(key, hu, en string, field func(p *PropertyInfo) *int) Column {
+	return Column{Key: key, Hu: hu, En: en, Numeric: true,
+		get: func(g PropertyGroup) string { return strconv.Itoa(*field(&g.Canonical)) },
+		set: func(p *PropertyInfo, v string) error {
+			i, err := strconv.Atoi(v)
+			*field(p) = i
+			return err
+		},
+	}
+}
+
+func floatColumn(key, hu, en string, precision int, field func(p *PropertyInfo) *float64) Column {
+	return Column{Key: key, Hu: hu, En: en, Numeric: true,
+		get: func(g PropertyGroup) string { return strconv.FormatFloat(*field(&g.Canonical), 'f', precision, 64) },
+		set: func(p *PropertyInfo, v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			*field(p) = f
+			return err
+		},
+	}
+}
+
+// enumColumn is a text column of a named string type, which cannot be pointed to as *string.
+func enumColumn(key, hu, en string, get func(p PropertyInfo) string, set func(p *PropertyInfo, v string)) Column {
+	return Column{Key: key, Hu: hu, En: en,
+		get: func(g PropertyGroup) string { return get(g.Canonical) },
+		set: func(p *PropertyInfo, v string) error { set(p, v); return nil },
+	}
+}
+
+// Columns lists every column in the default order. New columns go to the
+// end, so the csv files written earlier can still be read.
+var Columns = []Column{
+	textColumn("address", "Cím", "Address", func(p *PropertyInfo) *string { return &p.Address }),
+	textColumn("url", "URL", "URL", func(p *PropertyInfo) *string { return &p.Link }),
+	textColumn("condition", "Állapot", "Condition", func(p *PropertyInfo) *string { return &p.Condition }),
+	textColumn("parking", "Parkolás", "Parking", func(p *PropertyInfo) *string { return &p.Parking }),
+	textColumn("built_in", "Építés éve", "Built in", func(p *PropertyInfo) *string { return &p.BuiltIn }),
+	textColumn("num_of_floors", "Emeletek száma", "Number of floors", func(p *PropertyInfo) *string { return &p.NumOfFloors }),
+	textColumn("heating", "Fűtés", "Heating", func(p *PropertyInfo) *string { return &p.Heating }),
+	textColumn("air_conditioning", "Légkondicionálás", "Air conditioning", func(p *PropertyInfo) *string { return &p.AirConditioning }),
+	textColumn("toilet_and_bathroom", "WC/Fürdő", "Toilet/bathroom", func(p *PropertyInfo) *string { return &p.ToiletAndBathroom }),
+	intColumn("house_area", "Alapterület", "House area", func(p *PropertyInfo) *int { return &p.HouseArea }),
+	intColumn("lot_area", "Telekterület", "Lot area", func(p *PropertyInfo) *int { return &p.LotArea }),
+	intColumn("rooms", "Szobák száma", "Rooms", func(p *PropertyInfo) *int { return &p.NumOfRooms }),
+	floatColumn("price", "Ár", "Price (M Ft)", 2, func(p *PropertyInfo) *float64 { return &p.Price }),
+	floatColumn("price_per_sqr_meter", "Négyzetméter Ár", "Price per m2", 2, func(p *PropertyInfo) *float64 { return &p.PricePerSqrMeter }),
+	textColumn("portal", "Portál", "Portal", func(p *PropertyInfo) *string { return &p.Portal }),
+	textColumn("listing_id", "Azonosító", "Listing id", func(p *PropertyInfo) *string { return &p.ListingId }),
+	enumColumn("condition_category", "Állapot kategória", "Condition category",
+		func(p PropertyInfo) string { return string(p.ConditionCategory) },
+		func(p *PropertyInfo, v string) { p.ConditionCategory = ConditionCategory(v) }),
+	enumColumn("heating_type", "Fűtés típusa", "Heating type",
+		func(p PropertyInfo) string { return string(p.HeatingType) },
+		func(p *PropertyInfo, v string) { p.HeatingType = HeatingType(v) }),
+	enumColumn("parking_type", "Parkolás típusa", "Parking type",
+		func(p PropertyInfo) string { return string(p.ParkingType) },
+		func(p *PropertyInfo, v string) { p.ParkingType = ParkingType(v) }),
+	enumColumn("air_conditioning_type", "Légkondicionálás típusa", "Air conditioning type",
+		func(p PropertyInfo) string { return string(p.AirConditioningType) },
+		func(p *PropertyInfo, v string) { p.AirConditioningType = AirConditioningType(v) }),
+	intColumn("build_year_from", "Építés éve (tól)", "Built from", func(p *PropertyInfo) *int { return &p.BuildYearFrom }),
+	intColumn("build_year_to", "Építés éve (ig)", "Built until", func(p *PropertyInfo) *int { return &p.BuildYearTo }),
+	intColumn("floors", "Szintek", "Floors", func(p *PropertyInfo) *int { return &p.Floors }),
+	textColumn("search", "Keresés", "Search", func(p *PropertyInfo) *string { return &p.Search }),
+	enumColumn("transaction", "Ügylet", "Transaction",
+		func(p PropertyInfo) string { return string(p.Transaction.OrDefault()) },
+		func(p *PropertyInfo, v string) {
+			// sales keep the zero value, see PropertyInfo.Transaction
+			if TransactionType(v) == Rent {
+				p.Transaction = Rent
+			}
+		}),
+	floatColumn("monthly_rent", "Havi bérleti díj", "Monthly rent", 0, func(p *PropertyInfo) *float64 { return &p.MonthlyRent }),
+	floatColumn("deposit", "Kaució", "Deposit", 0, func(p *PropertyInfo) *float64 { return &p.Deposit }),
+	textColumn("utilities_included", "Rezsi", "Utilities", func(p *PropertyInfo) *string { return &p.UtilitiesIncluded }),
+	textColumn("min_lease_term", "Min. bérleti idő", "Min. lease term", func(p *PropertyInfo) *string { return &p.MinLeaseTerm }),
+	floatColumn("latitude", "Szélesség", "Latitude", -1, func(p *PropertyInfo) *float64 { return &p.Latitude }),
+	floatColumn("longitude", "Hosszúság", "Longitude", -1, func(p *PropertyInfo) *float64 { return &p.Longitude }),
+	{Key: "cheapest_portal", Hu: "Legolcsóbb portál", En: "Cheapest portal",
+		get: func(g PropertyGroup) string { return g.CheapestPortal }},
+	{Key: "sources", Hu: "Források", En: "Sources",
+		get: func(g PropertyGroup) string {
+			var links []string
+			for _, s := range g.Sources {
+				links = append(links, s.Link)
+			}
+			return strings.Join(links, " ")
+		}},
+}
+
+// listingColumns are the columns describing a single listing.
+func listingColumns() []Column {
+	var columns []Column
+	for _, c := range Columns {
+		if c.set != nil {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// SelectColumns returns the columns with the given keys in the given order, or every column.
+func SelectColumns(keys []string) ([]Column, error) {
+	if len(keys) == 0 {
+		return Columns, nil
+	}
+
+	var selected []Column
+	for _, key := range keys {
+		found := false
+		for _, c := range Columns {
+			if c.Key == strings.TrimSpace(key) {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column: '%s'", key)
+		}
+	}
+	return selected, nil
+}
+
+// ColumnKeys returns the keys of every column in the default order.
+func ColumnKeys() []string {
+	keys := make([]string, len(Columns))
+	for i, c := range Columns {
+		keys[i] = c.Key
+	}
+	return keys
+}
+
+func columnByKey(key string) (Column, bool) {
+	for _, c := range Columns {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// columnByHeader finds the listing column with the header in any language.
+func columnByHeader(header string) (Column, bool) {
+	header = strings.TrimSpace(header)
+	for _, c := range listingColumns() {
+		if c.Hu == header || c.En == header {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
@@ -2,41 +2,197 @@ package crawlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
-type Config struct {
-	Districts []string `json:"kerületek"`
+// Search is a single saved search run against the enabled portals.
+type Search struct {
+	Name      string   `json:"név"`
+	Districts []string `json:"kerületek"` // Budapest districts as roman numerals
+	Locations []string `json:"helyek"`    // districts, cities or counties, see ParseLocation
 	MinPrice  int      `json:"min_ár"`
 	MaxPrice  int      `json:"max_ár"`
 	MinSize   int      `json:"min_méret"`
 	MaxSize   int      `json:"max_méret"`
 	Type      string   `json:"lakás_vagy_ház"`
+	Portals   []string `json:"portálok"`
+
+	// Transaction is "elado" (the default) or "kiado". For rentals the price
+	// limits are the monthly rent in thousand HUF instead of million HUF.
+	Transaction TransactionType `json:"ügylet"`
+
+	Filter FilterConfig `json:"szűrők"`
+
+	// Outputs are the formats the results are written in, csv by default.
+	Outputs []string `json:"kimenetek"`
+}
+
+// ResolveLocations returns the districts and the other locations of the
+// search. Unknown entries are skipped and reported in the error.
+func (s Search) ResolveLocations() ([]Location, error) {
+	var locations []Location
+	var problems []string
+	resolve := func(field string, ids []string) {
+		for i, id := range ids {
+			l, err := ParseLocation(id)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s[%d]: %s", field, i, err))
+				continue
+			}
+			locations = append(locations, l)
+		}
+	}
+	resolve("kerületek", s.Districts)
+	resolve("helyek", s.Locations)
+
+	if len(problems) != 0 {
+		return locations, errors.New(strings.Join(problems, "; "))
+	}
+	return locations, nil
+}
+
+// Config holds the saved searches and the settings shared by them. The
+// embedded Search keeps the configs written before named searches working:
+// it is used when there are no searches under "keresések".
+type Config struct {
+	Search
+	Searches []Search `json:"keresések"`
+
+	Http       FetcherConfig        `json:"http"`
+	Workers    int                  `json:"párhuzamos_letöltések"`
+	RateLimits map[string]RateLimit `json:"sebességkorlátok"` // keyed by portal name
+
+	// BaseUrls replace the addresses of the portals, keyed by portal name.
+	// Used for crawling a local copy or a fake server in tests.
+	BaseUrls map[string]string `json:"portál_címek"`
+	// ExtractionRules is the path of a rules file replacing the built-in
+	// extractors of the portals listed in it, see ExtractionRule.
+	ExtractionRules string `json:"kinyerési_szabályok"`
+
+	StorePath string      `json:"adatbázis"`
+	Matching  MatchConfig `json:"duplikáció_keresés"`
+	Csv       CsvConfig   `json:"csv"`
+
+	Health HealthConfig `json:"állapotfigyelés"`
+}
+
+const defaultSearchName = "alap"
+
+// AllSearches returns the searches to run, each of them having a name.
+func (c Config) AllSearches() []Search {
+	if len(c.Searches) == 0 {
+		s := c.Search
+		if len(s.Name) == 0 {
+			s.Name = defaultSearchName
+		}
+		return []Search{s}
+	}
+
+	searches := make([]Search, len(c.Searches))
+	for i, s := range c.Searches {
+		if len(s.Name) == 0 {
+			s.Name = fmt.Sprintf("%s_%d", defaultSearchName, i+1)
+		}
+		searches[i] = s
+	}
+	return searches
 }
 
-func ReadJsonConfig(configfile string) (Config, error) {
+// LoadConfig reads a json, yaml or toml config, chosen by the extension of
+// the file, and applies the INGATLAN_* environment variables on top of it.
+// Every format uses the same keys as the json config, toml needs them quoted
+// because of the accents, e.g. "max_ár" = 90.
+//
+// Precedence, lowest first: built-in defaults, the config file, environment
+// variables, command line flags. See ApplyEnvOverrides for the variable names.
+func LoadConfig(configfile string) (Config, error) {
 	file, err := ioutil.ReadFile(configfile)
 	if err != nil {
 		return Config{}, err
 	}
 
-	var config Config
-
-	err = json.Unmarshal([]byte(file), &config)
+	doc, err := decodeConfigDocument(file, strings.ToLower(filepath.Ext(configfile)))
 	if err != nil {
+		return Config{}, fmt.Errorf("%s: %s", configfile, err)
+	}
+	if err := ApplyEnvOverrides(doc, os.Environ()); err != nil {
 		return Config{}, err
 	}
+	config, err := configFromDocument(doc)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %s", configfile, err)
+	}
+	return config, nil
+}
 
+// configFromDocument turns the decoded config document into a Config.
+func configFromDocument(doc map[string]interface{}) (Config, error) {
+	// all formats end up as json, so the json tags are the only key mapping
+	asJson, err := json.Marshal(doc)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	if err := json.Unmarshal(asJson, &config); err != nil {
+		return Config{}, err
+	}
 	return config, nil
 }
 
-func CreateFileNameFromConfig(c Config, prefix string) string {
+// decodeConfigDocument decodes the config into the generic form json uses,
+// whatever its format was.
+func decodeConfigDocument(file []byte, ext string) (map[string]interface{}, error) {
+	var decoded interface{}
+	switch ext {
+	case ".json", "":
+		decoded = json.RawMessage(file)
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(file, &decoded); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(file), &decoded); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format '%s', expected .json, .yaml, .yml or .toml", ext)
+	}
+
+	asJson, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(asJson, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil { // empty file
+		doc = make(map[string]interface{})
+	}
+	return doc, nil
+}
+
+func CreateFileNameFromSearch(s Search, prefix string) string {
 	if !strings.HasSuffix(prefix, "_") && len(prefix) != 0 {
 		prefix += "_"
 	}
-	districts := strings.Join(c.Districts, "_")
+	places := append([]string{}, s.Districts...)
+	for _, l := range s.Locations {
+		places = append(places, slugify(l))
+	}
+	districts := strings.Join(places, "_")
+
+	if s.Transaction.OrDefault() == Rent {
+		prefix += "kiado_"
+	}
 
-	return fmt.Sprintf("%sar_%d_%d_meret_%d_%d_kerulet_%s_%s.csv", prefix, c.MinPrice, c.MaxPrice, c.MinSize, c.MaxSize, districts, c.Type)
+	return fmt.Sprintf("%sar_%d_%d_meret_%d_%d_kerulet_%s_%s.csv", prefix, s.MinPrice, s.MaxPrice, s.MinSize, s.MaxSize, districts, s.Type)
 }
@@ -0,0 +1,242 @@
+package crawlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const envPrefix = "INGATLAN_"
+
+// ApplyEnvOverrides sets the values of the INGATLAN_* variables in environ
+// (as returned by os.Environ) on the decoded config document.
+//
+// The variable names are the json keys without accents in upper case, nested
+// keys and list indices joined by underscores:
+//
+//	INGATLAN_MAX_AR=90                      max_ár of every search
+//	INGATLAN_KERESESEK_1_MAX_AR=90          max_ár of the second search only
+//	INGATLAN_HTTP_IDOKORLAT_MP=10           http.időkorlát_mp
+//	INGATLAN_SEBESSEGKORLATOK_DUNAHOUSE_KERES_PER_MP=1
+//	INGATLAN_KERULETEK=xi,xii               lists are comma separated
+//
+// A search field set without an index applies to the top level search and to
+// every search under "keresések". Unknown variables and indices past the end
+// of a list are reported as errors.
+func ApplyEnvOverrides(doc map[string]interface{}, environ []string) error {
+	type override struct {
+		path  []interface{}
+		value interface{}
+	}
+	var overrides []override
+	var problems []string
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], envPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(parts[0], envPrefix))
+		path, leaf, err := resolveEnvPath(reflect.TypeOf(Config{}), name, doc)
+		if err == nil {
+			var value interface{}
+			value, err = parseEnvValue(leaf, parts[1])
+			overrides = append(overrides, override{path, value})
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", parts[0], err))
+		}
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("invalid environment override(s): %s", strings.Join(problems, "; "))
+	}
+
+	// the overrides of every search go first, so the ones of a single search win
+	sort.SliceStable(overrides, func(i, j int) bool {
+		return isSearchPath(overrides[i].path) && !isSearchPath(overrides[j].path)
+	})
+	for _, o := range overrides {
+		setDocumentValue(doc, o.path, o.value)
+		if !isSearchPath(o.path) {
+			continue
+		}
+		if searches, ok := doc["keresések"].([]interface{}); ok {
+			for i := range searches {
+				setDocumentValue(doc, append([]interface{}{"keresések", i}, o.path...), o.value)
+			}
+		}
+	}
+	return nil
+}
+
+// isSearchPath reports whether the path points into a field of the top level
+// search, e.g. max_ár or szűrők.min_szobák.
+func isSearchPath(path []interface{}) bool {
+	key, _ := path[0].(string)
+	for _, f := range structFields(reflect.TypeOf(Search{})) {
+		if jsonKey(f) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveEnvPath finds the document path of the env name in type t. The path
+// holds string keys and int list indices. Map keys are matched against the
+// keys already in the document and the portal names.
+func resolveEnvPath(t reflect.Type, name string, doc interface{}) ([]interface{}, reflect.Type, error) {
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := structFields(t)
+		// try longer keys first, a shorter key may be the prefix of a longer one
+		sort.Slice(fields, func(i, j int) bool { return len(jsonKey(fields[i])) > len(jsonKey(fields[j])) })
+		for _, f := range fields {
+			key := jsonKey(f)
+			env := envName(key)
+			if name == env {
+				return []interface{}{key}, f.Type, nil
+			}
+			if strings.HasPrefix(name, env+"_") {
+				rest, leaf, err := resolveEnvPath(f.Type, strings.TrimPrefix(name, env+"_"), childOf(doc, key))
+				if err != nil {
+					return nil, nil, err
+				}
+				return append([]interface{}{key}, rest...), leaf, nil
+			}
+		}
+
+	case reflect.Slice:
+		parts := strings.SplitN(name, "_", 2)
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			break
+		}
+		list, _ := doc.([]interface{})
+		if index < 0 || index >= len(list) {
+			return nil, nil, fmt.Errorf("index %d is past the end of the list, the config has %d item(s)", index, len(list))
+		}
+		rest, leaf, err := resolveEnvPath(t.Elem(), parts[1], childOf(doc, index))
+		if err != nil {
+			return nil, nil, err
+		}
+		return append([]interface{}{index}, rest...), leaf, nil
+
+	case reflect.Map:
+		candidates := PortalNames()
+		if m, ok := doc.(map[string]interface{}); ok {
+			for k := range m {
+				candidates = append(candidates, k)
+			}
+		}
+		for _, key := range candidates {
+			env := envName(key)
+			if strings.HasPrefix(name, env+"_") {
+				rest, leaf, err := resolveEnvPath(t.Elem(), strings.TrimPrefix(name, env+"_"), childOf(doc, key))
+				if err != nil {
+					return nil, nil, err
+				}
+				return append([]interface{}{key}, rest...), leaf, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no config key matches '%s'", name)
+}
+
+// structFields returns the fields of t with a json key, flattening embedded
+// structs the way encoding/json does.
+func structFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, structFields(f.Type)...)
+			continue
+		}
+		if len(jsonKey(f)) != 0 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func jsonKey(f reflect.StructField) string {
+	key := strings.Split(f.Tag.Get("json"), ",")[0]
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// envName turns a json key into the form used in variable names, e.g. "lakás_vagy_ház" -> "lakas_vagy_haz".
+func envName(key string) string {
+	return strings.Replace(slugify(key), "-", "_", -1)
+}
+
+func parseEnvValue(t reflect.Type, raw string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Int:
+		return strconv.Atoi(strings.TrimSpace(raw))
+	case reflect.Float64:
+		return strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	case reflect.Bool:
+		return strconv.ParseBool(strings.TrimSpace(raw))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			var items []interface{}
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); len(item) != 0 {
+					items = append(items, item)
+				}
+			}
+			return items, nil
+		}
+	}
+	return nil, errors.New("cannot be set from the environment")
+}
+
+func childOf(doc interface{}, key interface{}) interface{} {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			return d[k]
+		}
+	case []interface{}:
+		if i, ok := key.(int); ok && i < len(d) {
+			return d[i]
+		}
+	}
+	return nil
+}
+
+// setDocumentValue sets the value at path, creating the missing objects on
+// the way. List indices must be in range, see resolveEnvPath.
+func setDocumentValue(doc map[string]interface{}, path []interface{}, value interface{}) {
+	var set func(node interface{}, path []interface{}) interface{}
+	set = func(node interface{}, path []interface{}) interface{} {
+		if len(path) == 0 {
+			return value
+		}
+		switch key := path[0].(type) {
+		case string:
+			m, ok := node.(map[string]interface{})
+			if !ok {
+				m = make(map[string]interface{})
+			}
+			m[key] = set(m[key], path[1:])
+			return m
+		case int:
+			l, _ := node.([]interface{})
+			if key >= len(l) {
+				return node
+			}
+			l[key] = set(l[key], path[1:])
+			return l
+		}
+		return node
+	}
+	set(doc, path)
+}
@@ -0,0 +1,176 @@
+package crawlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	jsonTestConfig = `{
+	"max_ár": 80,
+	"keresések": [
+		{"név": "buda", "kerületek": ["xi"]},
+		{"név": "pest", "kerületek": ["xiii"], "max_ár": 70}
+	],
+	"http": {"időkorlát_mp": 5}
+}`
+
+	yamlTestConfig = `
+max_ár: 80
+keresések:
+  - név: buda
+    kerületek: [xi]
+  - név: pest
+    kerületek: [xiii]
+    max_ár: 70
+http:
+  időkorlát_mp: 5
+`
+
+	tomlTestConfig = `
+"max_ár" = 80
+
+[http]
+"időkorlát_mp" = 5
+
+[["keresések"]]
+"név" = "buda"
+"kerületek" = ["xi"]
+
+[["keresések"]]
+"név" = "pest"
+"kerületek" = ["xiii"]
+"max_ár" = 70
+`
+)
+
+// parseTestConfig does what LoadConfig does with a file of the extension,
+// using environ instead of the environment of the process.
+func parseTestConfig(content, ext string, environ []string) (Config, error) {
+	doc, err := decodeConfigDocument([]byte(content), ext)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := ApplyEnvOverrides(doc, environ); err != nil {
+		return Config{}, err
+	}
+	return configFromDocument(doc)
+}
+
+func TestConfigFormats(t *testing.T) {
+	tests := []struct {
+		ext     string
+		content string
+	}{
+		{".json", jsonTestConfig},
+		{".yaml", yamlTestConfig},
+		{".yml", yamlTestConfig},
+		{".toml", tomlTestConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			c, err := parseTestConfig(tt.content, tt.ext, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.MaxPrice != 80 || c.Http.TimeoutSeconds != 5 {
+				t.Errorf("expected max_ár 80 and időkorlát_mp 5, got %d and %d", c.MaxPrice, c.Http.TimeoutSeconds)
+			}
+			if len(c.Searches) != 2 || c.Searches[0].Name != "buda" || c.Searches[1].MaxPrice != 70 ||
+				!reflect.DeepEqual(c.Searches[1].Districts, []string{"xiii"}) {
+				t.Errorf("unexpected searches: %+v", c.Searches)
+			}
+		})
+	}
+
+	if _, err := parseTestConfig(jsonTestConfig, ".ini", nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		check   func(c Config) bool
+	}{
+		{
+			"search field of every search",
+			[]string{"INGATLAN_MAX_AR=90"},
+			func(c Config) bool {
+				return c.MaxPrice == 90 && c.Searches[0].MaxPrice == 90 && c.Searches[1].MaxPrice == 90
+			},
+		},
+		{
+			"indexed search field wins",
+			[]string{"INGATLAN_KERESESEK_1_MAX_AR=60", "INGATLAN_MAX_AR=90"},
+			func(c Config) bool {
+				return c.Searches[0].MaxPrice == 90 && c.Searches[1].MaxPrice == 60
+			},
+		},
+		{
+			"nested",
+			[]string{"INGATLAN_HTTP_IDOKORLAT_MP=10", "INGATLAN_SZUROK_MIN_SZOBAK=3"},
+			func(c Config) bool {
+				return c.Http.TimeoutSeconds == 10 && c.Filter.MinRooms == 3 && c.Searches[1].Filter.MinRooms == 3
+			},
+		},
+		{
+			"map keyed by portal",
+			[]string{"INGATLAN_SEBESSEGKORLATOK_DUNAHOUSE_KERES_PER_MP=1.5"},
+			func(c Config) bool { return c.RateLimits["dunahouse"].RequestsPerSecond == 1.5 },
+		},
+		{
+			"comma separated list",
+			[]string{"INGATLAN_KERESESEK_0_KERULETEK=xi, xii"},
+			func(c Config) bool { return reflect.DeepEqual(c.Searches[0].Districts, []string{"xi", "xii"}) },
+		},
+		{
+			"other variables are ignored",
+			[]string{"HOME=/root", "INGATLAN=1", "MY_INGATLAN_MAX_AR=1"},
+			func(c Config) bool { return c.MaxPrice == 80 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseTestConfig(jsonTestConfig, ".json", tt.environ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(c) {
+				t.Errorf("overrides %v were not applied: %+v", tt.environ, c)
+			}
+		})
+	}
+}
+
+func TestInvalidEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable string
+		message  string
+	}{
+		{"unknown key", "INGATLAN_NINCS_ILYEN=1", "no config key matches"},
+		{"unknown nested key", "INGATLAN_HTTP_NINCS_ILYEN=1", "no config key matches"},
+		{"not a number", "INGATLAN_MAX_AR=sok", "invalid syntax"},
+		{"not a bool", "INGATLAN_ALLAPOTFIGYELES_KIKAPCSOLVA=talan", "invalid syntax"},
+		{"index past the end", "INGATLAN_KERESESEK_5_MAX_AR=90", "past the end"},
+		{"not settable", "INGATLAN_SEBESSEGKORLATOK=1", "cannot be set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestConfig(jsonTestConfig, ".json", []string{tt.variable})
+			if err == nil {
+				t.Fatalf("expected an error for %s", tt.variable)
+			}
+			name := strings.SplitN(tt.variable, "=", 2)[0]
+			if !strings.Contains(err.Error(), name+": ") || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected an error naming %s with '%s', got: %s", name, tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package crawlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+const utf8Bom = "\ufeff"
+
+// CsvConfig tunes the tabular outputs. The defaults are the plain csv the
+// crawler always wrote; Hungarian Excel reads ';', ',' and the BOM well.
+// The column selection and the language apply to xlsx as well.
+type CsvConfig struct {
+	Columns          []string `json:"oszlopok"`   // column keys, see Columns; default: all
+	Language         string   `json:"nyelv"`      // "hu" (default) or "en"
+	Delimiter        string   `json:"elválasztó"` // default ","
+	DecimalSeparator string   `json:"tizedesjel"` // "." (default) or ","
+	Bom              bool     `json:"bom"`
+}
+
+func (cc CsvConfig) delimiter() rune {
+	if len(cc.Delimiter) == 0 {
+		return ','
+	}
+	r, _ := utf8.DecodeRuneInString(cc.Delimiter)
+	return r
+}
+
+// format returns the value of the column for the group as written to the file.
+func (cc CsvConfig) format(c Column, g PropertyGroup) string {
+	v := c.get(g)
+	if c.Numeric && cc.DecimalSeparator == "," {
+		v = strings.Replace(v, ".", ",", 1)
+	}
+	return v
+}
+
+// writeCsv writes a header row and a row for every group.
+func writeCsv(w io.Writer, cc CsvConfig, columns []Column, groups []PropertyGroup) error {
+	if cc.Bom {
+		if _, err := io.WriteString(w, utf8Bom); err != nil {
+			return err
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = cc.delimiter()
+
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.Header(cc.Language)
+	}
+	if err := writer.Write(row); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for i, c := range columns {
+			row[i] = cc.format(c, g)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadPropertiesFromCsv reads back a csv written by any of the csv writers.
+// Columns are matched by their header in either language, unknown columns are
+// ignored. The delimiter, a BOM and ',' decimal separators are detected.
+func ReadPropertiesFromCsv(r io.Reader) ([]PropertyInfo, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte(utf8Bom))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("csv is empty, expected a header row")
+	}
+
+	columns := make(map[int]Column)
+	for i, h := range records[0] {
+		if c, ok := columnByHeader(h); ok {
+			columns[i] = c
+		}
+	}
+
+	var props []PropertyInfo
+	for line, record := range records[1:] {
+		var p PropertyInfo
+		for i, c := range columns {
+			if i >= len(record) || len(record[i]) == 0 {
+				continue
+			}
+			v := record[i]
+			if c.Numeric && !strings.Contains(v, ".") {
+				v = strings.Replace(v, ",", ".", 1)
+			}
+			if err := c.set(&p, v); err != nil {
+				return nil, fmt.Errorf("line %d, column '%s': %s", line+2, records[0][i], err)
+			}
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// detectDelimiter picks the most frequent of the usual delimiters in the header row.
+func detectDelimiter(data []byte) rune {
+	header := string(data)
+	if i := strings.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	best, bestCount := ',', strings.Count(header, ",")
+	for _, d := range []rune{';', '\t'} {
+		if n := strings.Count(header, string(d)); n > bestCount {
+			best, bestCount = d, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,204 @@
+package crawlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type ListingChange struct {
+	Key     ListingKey    `json:"key"`
+	Link    string        `json:"link"`
+	Address string        `json:"address"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// ChangeSet is the difference between two crawls.
+type ChangeSet struct {
+	From    *time.Time      `json:"from,omitempty"` // only set when comparing stored runs
+	To      *time.Time      `json:"to,omitempty"`
+	New     []PropertyInfo  `json:"new"`
+	Removed []PropertyInfo  `json:"removed"`
+	Changed []ListingChange `json:"changed"`
+}
+
+func (cs ChangeSet) IsEmpty() bool {
+	return len(cs.New) == 0 && len(cs.Removed) == 0 && len(cs.Changed) == 0
+}
+
+// DiffProperties compares two crawl results matching the listings by their key.
+func DiffProperties(old, current []PropertyInfo) ChangeSet {
+	oldByKey := make(map[ListingKey]PropertyInfo, len(old))
+	for _, p := range old {
+		oldByKey[p.Key()] = p
+	}
+	currentByKey := make(map[ListingKey]PropertyInfo, len(current))
+	for _, p := range current {
+		currentByKey[p.Key()] = p
+	}
+
+	cs := ChangeSet{New: []PropertyInfo{}, Removed: []PropertyInfo{}, Changed: []ListingChange{}}
+	for _, p := range current {
+		o, ok := oldByKey[p.Key()]
+		if !ok {
+			cs.New = append(cs.New, p)
+			continue
+		}
+		if changes := diffFields(o, p); len(changes) != 0 {
+			cs.Changed = append(cs.Changed, ListingChange{Key: p.Key(), Link: p.Link, Address: p.Address, Changes: changes})
+		}
+	}
+	for _, p := range old {
+		if _, ok := currentByKey[p.Key()]; !ok {
+			cs.Removed = append(cs.Removed, p)
+		}
+	}
+
+	sortProperties(cs.New)
+	sortProperties(cs.Removed)
+	sort.Slice(cs.Changed, func(i, j int) bool {
+		return cs.Changed[i].Key.String() < cs.Changed[j].Key.String()
+	})
+	return cs
+}
+
+// DiffLastRuns compares the last complete run recorded in the store with the
+// complete run of the same searches before it. Listings the latest run could
+// not fetch are not reported as removed.
+func DiffLastRuns(s Store) (ChangeSet, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	var complete []CrawlRun
+	for _, r := range runs {
+		if !r.Interrupted {
+			complete = append(complete, r)
+		}
+	}
+	if len(complete) < 2 {
+		return ChangeSet{}, errors.New("the store has less than two complete runs to compare")
+	}
+	to := complete[len(complete)-1]
+	var from *CrawlRun
+	for i := len(complete) - 2; i >= 0; i-- {
+		if complete[i].SameSearches(to.Searches) {
+			from = &complete[i]
+			break
+		}
+	}
+	if from == nil {
+		return ChangeSet{}, fmt.Errorf("the store has no complete run before the last one with the same searches (%s) to compare", strings.Join(to.Searches, ", "))
+	}
+
+	old, err := snapshotOfRun(s, *from)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	current, err := snapshotOfRun(s, to)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	cs := DiffProperties(old, current)
+	cs.From, cs.To = &from.StartedAt, &to.StartedAt
+	cs.Removed = withoutKeys(cs.Removed, to.FailedKeys)
+	return cs, nil
+}
+
+// snapshotOfRun returns the listings seen by the run as they were at that time.
+func snapshotOfRun(s Store, run CrawlRun) ([]PropertyInfo, error) {
+	var props []PropertyInfo
+	for _, key := range run.Keys {
+		l, ok, err := s.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if p, ok := l.VersionAt(run.StartedAt); ok {
+			props = append(props, p)
+		}
+	}
+	return props, nil
+}
+
+func withoutKeys(props []PropertyInfo, keys []ListingKey) []PropertyInfo {
+	excluded := make(map[ListingKey]bool, len(keys))
+	for _, k := range keys {
+		excluded[k] = true
+	}
+	kept := []PropertyInfo{}
+	for _, p := range props {
+		if !excluded[p.Key()] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func diffFields(old, current PropertyInfo) []FieldChange {
+	var changes []FieldChange
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(current)
+	for i := 0; i < ov.NumField(); i++ {
+		of, nf := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(of, nf) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field: ov.Type().Field(i).Name,
+			Old:   fmt.Sprint(of),
+			New:   fmt.Sprint(nf),
+		})
+	}
+	return changes
+}
+
+func sortProperties(props []PropertyInfo) {
+	sort.Slice(props, func(i, j int) bool {
+		return props[i].Key().String() < props[j].Key().String()
+	})
+}
+
+// WriteText writes the change set in a human readable form.
+func (cs ChangeSet) WriteText(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	if cs.From != nil && cs.To != nil {
+		printf("Changes between %s and %s\n\n", cs.From.Format("2006-01-02 15:04"), cs.To.Format("2006-01-02 15:04"))
+	}
+
+	printf("New listings (%d):\n", len(cs.New))
+	for _, p := range cs.New {
+		printf("  + %s  %s  %s  %d m2\n", p.Link, p.Address, formatListedPrice(p), p.HouseArea)
+	}
+	printf("\nRemoved listings (%d):\n", len(cs.Removed))
+	for _, p := range cs.Removed {
+		printf("  - %s  %s  %s  %d m2\n", p.Link, p.Address, formatListedPrice(p), p.HouseArea)
+	}
+	printf("\nChanged listings (%d):\n", len(cs.Changed))
+	for _, c := range cs.Changed {
+		printf("  * %s  %s\n", c.Link, c.Address)
+		for _, fc := range c.Changes {
+			printf("      %s: '%s' -> '%s'\n", fc.Field, fc.Old, fc.New)
+		}
+	}
+	return err
+}
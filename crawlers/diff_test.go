@@ -0,0 +1,95 @@
+package crawlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// storedRun is a run to record in the test store with the listings it saw.
+type storedRun struct {
+	searches []string
+	props    []PropertyInfo
+	failed   []ListingKey
+}
+
+func storeWithRuns(t *testing.T, runs ...storedRun) Store {
+	t.Helper()
+
+	s, err := OpenJsonFileStore(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	startedAt := time.Date(2021, 9, 1, 8, 0, 0, 0, time.UTC)
+	for i, r := range runs {
+		run := CrawlRun{StartedAt: startedAt.Add(time.Duration(i) * 24 * time.Hour), Searches: r.searches, FailedKeys: r.failed}
+		for _, p := range r.props {
+			if err := s.Upsert(p.Key(), p, run.StartedAt); err != nil {
+				t.Fatal(err)
+			}
+			run.Keys = append(run.Keys, p.Key())
+		}
+		if err := s.AddRun(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func listing(id string, price float64) PropertyInfo {
+	return PropertyInfo{Portal: "ingatlan.com", ListingId: id, Link: "https://ingatlan.com/" + id, Price: price, HouseArea: 100}
+}
+
+func keysOf(props []PropertyInfo) []string {
+	keys := []string{}
+	for _, p := range props {
+		keys = append(keys, p.ListingId)
+	}
+	return keys
+}
+
+func TestDiffLastRunsSkipsFailedListings(t *testing.T) {
+	s := storeWithRuns(t,
+		storedRun{props: []PropertyInfo{listing("1", 60), listing("2", 70), listing("3", 80)}},
+		storedRun{props: []PropertyInfo{listing("1", 65)}, failed: []ListingKey{listing("2", 0).Key()}},
+	)
+
+	cs, err := DiffLastRuns(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := keysOf(cs.Removed); len(removed) != 1 || removed[0] != "3" {
+		t.Errorf("expected only the listing missing from the portal to be removed, got %v", removed)
+	}
+	if len(cs.Changed) != 1 || cs.Changed[0].Key.Id != "1" {
+		t.Errorf("expected the price change of listing 1, got %+v", cs.Changed)
+	}
+}
+
+func TestDiffLastRunsComparesRunsOfTheSameSearches(t *testing.T) {
+	all := []string{"buda", "pest"}
+	s := storeWithRuns(t,
+		storedRun{searches: all, props: []PropertyInfo{listing("1", 60), listing("2", 70)}},
+		storedRun{searches: []string{"buda"}, props: []PropertyInfo{listing("1", 60)}},
+		storedRun{searches: all, props: []PropertyInfo{listing("1", 60), listing("2", 70), listing("3", 80)}},
+	)
+
+	cs, err := DiffLastRuns(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.Removed) != 0 {
+		t.Errorf("expected no removed listings compared to the run of every search, got %v", keysOf(cs.Removed))
+	}
+	if added := keysOf(cs.New); len(added) != 1 || added[0] != "3" {
+		t.Errorf("expected listing 3 to be new, got %v", added)
+	}
+
+	s = storeWithRuns(t,
+		storedRun{searches: all, props: []PropertyInfo{listing("1", 60), listing("2", 70)}},
+		storedRun{searches: []string{"buda"}, props: []PropertyInfo{listing("1", 60)}},
+	)
+	if _, err := DiffLastRuns(s); err == nil {
+		t.Error("expected an error without an earlier run of the same searches")
+	}
+}
@@ -3,6 +3,7 @@ package crawlers
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -11,21 +12,98 @@ import (
 
 var DunaHouseBaseUrl string = "https://dh.hu/"
 
-func CreateDunaHouseQueryUrl(c Config) string {
-	url := JoinUri(DunaHouseBaseUrl, "elado-ingatlan")
+func init() {
+	RegisterPortal(DunaHousePortal{})
+}
+
+type DunaHousePortal struct{}
+
+func (DunaHousePortal) Name() string {
+	return "dunahouse"
+}
+
+func (DunaHousePortal) BaseUrl() string {
+	return DunaHouseBaseUrl
+}
+
+func (DunaHousePortal) SetBaseUrl(baseUrl string) {
+	DunaHouseBaseUrl = baseUrl
+}
+
+func (DunaHousePortal) QueryUrl(s Search) string {
+	return CreateDunaHouseQueryUrl(s)
+}
+
+var dunaHouseListingIdRegexp = regexp.MustCompile(`^([A-Za-z]*\d+)$`)
+
+// ListingId returns the reference number ending the listing urls, e.g.
+// https://dh.hu/elado-ingatlan/haz/budapest-xi-kerulet/H123456
+func (DunaHousePortal) ListingId(link string) (string, error) {
+	id, err := lastPathSegmentMatching(link, dunaHouseListingIdRegexp)
+	return strings.ToUpper(id), err
+}
+
+var dunaHouseValueMappings = ValueMappings{
+	Conditions: map[string]ConditionCategory{
+		"új építésű":    ConditionNew,
+		"új":            ConditionNew,
+		"újszerű":       ConditionAsNew,
+		"felújított":    ConditionRenovated,
+		"jó":            ConditionGood,
+		"átlagos":       ConditionAverage,
+		"közepes":       ConditionAverage,
+		"felújítandó":   ConditionNeedsRenovation,
+		"építés alatt":  ConditionUnderConstruction,
+		"szerkezetkész": ConditionUnderConstruction,
+	},
+	Heatings: map[string]HeatingType{
+		"gázkazán":        HeatingGasBoiler,
+		"gáz (cirkó)":     HeatingGasBoiler,
+		"cirkó":           HeatingGasBoiler,
+		"gázkonvektor":    HeatingGasConvector,
+		"gáz (konvektor)": HeatingGasConvector,
+		"távfűtés":        HeatingDistrict,
+		"házközponti":     HeatingCentral,
+		"elektromos":      HeatingElectric,
+		"hőszivattyú":     HeatingHeatPump,
+		"vegyes tüzelésű": HeatingSolidFuel,
+		"padlófűtés":      HeatingUnderfloor,
+		"egyéb":           HeatingOther,
+	},
+}
+
+func (DunaHousePortal) ValueMappings() ValueMappings {
+	return dunaHouseValueMappings
+}
+
+func (DunaHousePortal) NewListingPagesExtractor() ListingPagesExtractor {
+	return &DunaHouseListingPagesExtractor{}
+}
+
+func (DunaHousePortal) NewLinkCollector() LinkExtractor {
+	return &DunaHouseLinkCollector{}
+}
+
+func (DunaHousePortal) NewPageDataExtractors() []PageDataExtractor {
+	return []PageDataExtractor{&DunaHouseMainInfoExtractor{}, &DunaHouseGeneralInfoExtractor{}, &GeoLocationExtractor{}}
+}
+
+func CreateDunaHouseQueryUrl(c Search) string {
+	url := JoinUri(DunaHouseBaseUrl, string(c.Transaction.OrDefault())+"-ingatlan")
 
 	districts := []string{}
-	for _, d := range c.Districts {
-		distNumber, err := getNumberForRomanNumeric(d)
-		if err != nil {
-			log.Println(err)
-		}
-		districts = append(districts, fmt.Sprintf("budapest-%d.-kerulet", distNumber))
+	locations, _ := c.ResolveLocations() // validated before crawling
+	for _, l := range locations {
+		districts = append(districts, dunaHouseLocationSlug(l))
 	}
 	url = JoinUri(url, c.Type)
 	url = JoinUri(url, strings.Join(districts, "+"))
 	url = JoinUri(url, "-")
-	url = JoinUri(url, fmt.Sprintf("%d-%d-mFt", c.MinPrice, c.MaxPrice))
+	if c.Transaction.OrDefault() == Rent {
+		url = JoinUri(url, fmt.Sprintf("%d-%d-eFt", c.MinPrice, c.MaxPrice))
+	} else {
+		url = JoinUri(url, fmt.Sprintf("%d-%d-mFt", c.MinPrice, c.MaxPrice))
+	}
 	url = JoinUri(url, fmt.Sprintf("%d-%d-m2", c.MinSize, c.MaxSize))
 
 	return url
@@ -40,9 +118,9 @@ func (lpe *DunaHouseListingPagesExtractor) Predicate(n *html.Node) bool {
 }
 
 func (lpe *DunaHouseListingPagesExtractor) ProcessNode(n *html.Node) {
-	pageNum, err := strconv.Atoi(n.FirstChild.Data)
+	pageNum, err := strconv.Atoi(nodeText(n))
 	if err != nil {
-		log.Printf("could not convert %s to int", n.FirstChild.Data)
+		log.Printf("could not convert %s to int", nodeText(n))
 	}
 
 	if pageNum > lpe.maxPageNumber {
@@ -87,6 +165,7 @@ func (lc *DunaHouseLinkCollector) GetNextPageFormatString() string {
 type DunaHouseGeneralInfoExtractor struct {
 	LotArea                                           int
 	Address, NumOfFloors, Heating, BuiltIn, Condition string
+	Deposit, UtilitiesIncluded, MinLeaseTerm          string
 }
 
 func (e *DunaHouseGeneralInfoExtractor) Predicate(n *html.Node) bool {
@@ -130,6 +209,12 @@ func (e *DunaHouseGeneralInfoExtractor) ProcessNode(n *html.Node) {
 					e.LotArea = 0
 				}
 				e.LotArea = area
+			case "Kaució:":
+				e.Deposit = paramVal
+			case "Rezsi:", "Rezsi benne van:":
+				e.UtilitiesIncluded = paramVal
+			case "Minimális bérleti idő:":
+				e.MinLeaseTerm = paramVal
 			}
 
 			paramName, paramVal = "", ""
@@ -144,10 +229,13 @@ func (e *DunaHouseGeneralInfoExtractor) AddInfoIntoProp(p *PropertyInfo) {
 	p.BuiltIn = e.BuiltIn
 	p.Condition = e.Condition
 	p.LotArea = e.LotArea
+	p.Deposit = depositInHuf(e.Deposit, p.MonthlyRent) // the main info extractor ran before
+	p.UtilitiesIncluded = e.UtilitiesIncluded
+	p.MinLeaseTerm = e.MinLeaseTerm
 }
 
 type DunaHouseMainInfoExtractor struct {
-	Price                 float64
+	Price, MonthlyRent    float64
 	HouseArea, NumOfRooms int
 }
 
@@ -159,27 +247,22 @@ func (e *DunaHouseMainInfoExtractor) ProcessNode(n *html.Node) {
 	var paramName, paramVal string
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if c.Data == "span" {
-			paramName = strings.TrimSpace(c.FirstChild.Data)
-		} else if c.Data == "div" && doesClassAttrContainsVal(c, "value") {
-			v := strings.TrimSpace(c.FirstChild.Data)
-			if v == "b" && c.FirstChild.FirstChild != nil {
-				paramVal = strings.TrimSpace(c.FirstChild.FirstChild.Data)
-			} else {
-				paramVal = v
-			}
+		if isNodeTypeOf(c, "span") {
+			paramName = nodeText(c)
+		} else if isDivNode(c) && doesClassAttrContainsVal(c, "value") {
+			paramVal = nodeText(c) // the price is inside a <b>
 		}
 	}
 
 	switch paramName {
-	case "Ár":
-		priceAsString := strings.Split(paramVal, " ")[0] // get rid of "<price> Ft"
-		price, err := strconv.ParseFloat(priceAsString, 2)
+	case "Ár", "Bérleti díj":
+		huf, monthly, err := parsePriceText(paramVal) // "85 M Ft" or "250 ezer Ft/hó"
 		if err != nil {
 			log.Printf("could not parse value: %s\n", paramVal)
 			e.Price = -1.0
+			return
 		}
-		e.Price = price
+		setListedPrice(huf, monthly || paramName == "Bérleti díj", &e.Price, &e.MonthlyRent)
 	case "Méret":
 		sizeAsString := strings.Split(paramVal, "m")[0] //140m2
 		area, err := strconv.Atoi(sizeAsString)
@@ -199,59 +282,29 @@ func (e *DunaHouseMainInfoExtractor) ProcessNode(n *html.Node) {
 	}
 }
 
+// Done reports whether the price, the area and the rooms were all found.
+func (e *DunaHouseMainInfoExtractor) Done() bool {
+	return (e.Price > 0 || e.MonthlyRent > 0) && e.HouseArea > 0 && e.NumOfRooms > 0
+}
+
 func (e *DunaHouseMainInfoExtractor) AddInfoIntoProp(prop *PropertyInfo) {
-	prop.PricePerSqrMeter = (e.Price / float64(e.HouseArea)) * 1000000.0
+	if e.HouseArea > 0 { // plots have no area to divide with
+		if e.MonthlyRent > 0 {
+			prop.PricePerSqrMeter = e.MonthlyRent / float64(e.HouseArea)
+		} else {
+			prop.PricePerSqrMeter = (e.Price / float64(e.HouseArea)) * 1000000.0
+		}
+	}
 	prop.HouseArea = e.HouseArea
 	prop.NumOfRooms = e.NumOfRooms
 	prop.Price = e.Price
+	prop.MonthlyRent = e.MonthlyRent
 }
 
-func getNumberForRomanNumeric(roman string) (int, error) {
-	switch strings.ToUpper(roman) {
-	case "I":
-		return 1, nil
-	case "II":
-		return 2, nil
-	case "III":
-		return 3, nil
-	case "IV":
-		return 4, nil
-	case "V":
-		return 5, nil
-	case "VI":
-		return 6, nil
-	case "VII":
-		return 7, nil
-	case "VIII":
-		return 8, nil
-	case "IX":
-		return 9, nil
-	case "X":
-		return 10, nil
-	case "XI":
-		return 11, nil
-	case "XII":
-		return 12, nil
-	case "XIII":
-		return 13, nil
-	case "XIV":
-		return 14, nil
-	case "XV":
-		return 15, nil
-	case "XVI":
-		return 16, nil
-	case "XVII":
-		return 17, nil
-	case "XVIII":
-		return 18, nil
-	case "XIX":
-		return 19, nil
-	case "XX":
-		return 20, nil
-	case "XXI":
-		return 21, nil
-	case "XXII":
-		return 22, nil
+// dunaHouseLocationSlug returns e.g. "budapest-11.-kerulet", "budaors" or "pest-megye".
+func dunaHouseLocationSlug(l Location) string {
+	if l.Kind == LocationDistrict {
+		return fmt.Sprintf("budapest-%d.-kerulet", l.District)
 	}
-	return 0, fmt.Errorf("could not parse given roman district number: %s", roman)
+	return l.Slug()
 }
@@ -0,0 +1,149 @@
+package crawlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type ErrorKind string
+
+const (
+	NetworkErrorKind      ErrorKind = "network"
+	HttpStatusErrorKind   ErrorKind = "http_status"
+	ParseErrorKind        ErrorKind = "parse"
+	MissingFieldErrorKind ErrorKind = "missing_field"
+	// IncompleteErrorKind is a warning, the listing is kept but a field
+	// usually there is missing, e.g. the area of a plot.
+	IncompleteErrorKind ErrorKind = "incomplete"
+	OtherErrorKind      ErrorKind = "other"
+)
+
+// CrawlError describes why a page could not be turned into usable data.
+type CrawlError struct {
+	Kind       ErrorKind
+	Url        string
+	StatusCode int    // only for HttpStatusErrorKind
+	Field      string // only for MissingFieldErrorKind
+	Err        error
+}
+
+func (e *CrawlError) Error() string {
+	switch e.Kind {
+	case HttpStatusErrorKind:
+		return fmt.Sprintf("unexpected status %d from '%s'", e.StatusCode, e.Url)
+	case MissingFieldErrorKind:
+		return fmt.Sprintf("could not extract '%s' from '%s'", e.Field, e.Url)
+	case IncompleteErrorKind:
+		return fmt.Sprintf("no '%s' on '%s', keeping the listing without it", e.Field, e.Url)
+	}
+	return fmt.Sprintf("%s error for '%s': %s", e.Kind, e.Url, e.Err)
+}
+
+func (e *CrawlError) Unwrap() error {
+	return e.Err
+}
+
+func newNetworkError(url string, err error) error {
+	return &CrawlError{Kind: NetworkErrorKind, Url: url, Err: err}
+}
+
+func newHttpStatusError(url string, statusCode int) error {
+	return &CrawlError{Kind: HttpStatusErrorKind, Url: url, StatusCode: statusCode}
+}
+
+func newParseError(url string, err error) error {
+	return &CrawlError{Kind: ParseErrorKind, Url: url, Err: err}
+}
+
+func newMissingFieldError(url, field string) error {
+	return &CrawlError{Kind: MissingFieldErrorKind, Url: url, Field: field}
+}
+
+func newIncompleteError(url, field string) error {
+	return &CrawlError{Kind: IncompleteErrorKind, Url: url, Field: field}
+}
+
+// IsIncomplete reports whether err is only a warning about a missing field,
+// the property returned along with it is usable.
+func IsIncomplete(err error) bool {
+	var ce *CrawlError
+	return errors.As(err, &ce) && ce.Kind == IncompleteErrorKind
+}
+
+// FailureReport collects the errors of a crawl run. It is safe for concurrent use.
+type FailureReport struct {
+	mu       sync.Mutex
+	failures []*CrawlError
+}
+
+func (r *FailureReport) Add(err error) {
+	if err == nil {
+		return
+	}
+
+	var ce *CrawlError
+	if !errors.As(err, &ce) {
+		ce = &CrawlError{Kind: OtherErrorKind, Err: err}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, ce)
+}
+
+func (r *FailureReport) Failures() []*CrawlError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*CrawlError(nil), r.failures...)
+}
+
+func (r *FailureReport) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.failures)
+}
+
+// Summary returns a short description of the number of failures per kind.
+func (r *FailureReport) Summary() string {
+	counts := make(map[ErrorKind]int)
+	for _, f := range r.Failures() {
+		counts[f.Kind]++
+	}
+
+	var parts []string
+	for k, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s: %d", k, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func (r *FailureReport) WriteCsv(filepath string) error {
+	f, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"Típus", "URL", "Státusz", "Mező", "Hiba"})
+	for _, failure := range r.Failures() {
+		status := ""
+		if failure.StatusCode != 0 {
+			status = strconv.Itoa(failure.StatusCode)
+		}
+		writer.Write([]string{string(failure.Kind), failure.Url, status, failure.Field, failure.Error()})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
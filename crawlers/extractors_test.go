@@ -0,0 +1,148 @@
+package crawlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Run `go test ./crawlers -update` after an intended change of the extractors
+// and review the diff of testdata/golden.
+var update = flag.Bool("update", false, "rewrite the golden files with the current results")
+
+const fixturesDir = "testdata/fixtures"
+
+// fixtureSearch is the search the listing pages in testdata/fixtures were saved for.
+var fixtureSearch = Search{
+	Name:      "teszt",
+	Districts: []string{"xi"},
+	MinPrice:  60,
+	MaxPrice:  90,
+	MinSize:   85,
+	MaxSize:   140,
+	Type:      "haz",
+}
+
+// replayFixtures serves every request of the test from testdata/fixtures.
+func replayFixtures(t *testing.T) {
+	previous := DefaultFetcher
+	DefaultFetcher = NewFetcher(FetcherConfig{FixtureMode: FixtureReplay, FixturesDir: fixturesDir})
+	t.Cleanup(func() { DefaultFetcher = previous })
+}
+
+func compareWithGolden(t *testing.T, name string, got interface{}) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read golden file, run with -update to create it: %s", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("result differs from %s, got:\n%s", path, actual)
+	}
+}
+
+func TestListingExtractors(t *testing.T) {
+	replayFixtures(t)
+
+	for _, name := range PortalNames() {
+		t.Run(name, func(t *testing.T) {
+			p, err := GetPortal(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			le := p.NewLinkCollector()
+			err = CollectPropertyLinksForQuery(context.Background(), p.QueryUrl(fixtureSearch), le, p.NewListingPagesExtractor())
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareWithGolden(t, name+"_links", le.GetLinks())
+		})
+	}
+}
+
+// detailPages are the saved detail pages with the golden file of each.
+var detailPages = []struct {
+	golden string
+	portal string
+	link   string
+}{
+	{"ingatlan.com_sale", "ingatlan.com", "/32145678"},
+	{"ingatlan.com_rent", "ingatlan.com", "/32999001"},
+	{"dunahouse_sale", "dunahouse", "/elado-ingatlan/haz/budapest-xi-kerulet/H123456"},
+	{"dunahouse_rent", "dunahouse", "/kiado-ingatlan/lakas/budapest-xi-kerulet/L987654"},
+	// indented markup with empty parameters, as the portals serve it
+	{"ingatlan.com_indented", "ingatlan.com", "/32145680"},
+	{"dunahouse_indented", "dunahouse", "/elado-ingatlan/haz/budapest-xi-kerulet/H123457"},
+}
+
+func TestDetailExtractors(t *testing.T) {
+	replayFixtures(t)
+	testDetailPages(t)
+}
+
+// TestDefaultExtractionRules checks that the built-in rule sets extract the
+// same as the built-in extractors, by comparing to the same golden files.
+func TestDefaultExtractionRules(t *testing.T) {
+	replayFixtures(t)
+	for portal, rules := range DefaultExtractionRules() {
+		if err := SetExtractionRules(portal, rules); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(ResetExtractionRules)
+
+	testDetailPages(t)
+}
+
+func testDetailPages(t *testing.T) {
+	for _, tt := range detailPages {
+		t.Run(tt.golden, func(t *testing.T) {
+			p, err := GetPortal(tt.portal)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			prop, err := CollectPropertyFromPortal(context.Background(), p, tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareWithGolden(t, tt.golden, prop)
+		})
+	}
+}
+
+func TestDetailExtractorsReportMissingListing(t *testing.T) {
+	replayFixtures(t)
+
+	p, err := GetPortal("ingatlan.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CollectPropertyFromPortal(context.Background(), p, "/32145679"); err == nil {
+		t.Error("expected an error for a listing answering 404")
+	}
+}
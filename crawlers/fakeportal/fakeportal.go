@@ -0,0 +1,331 @@
+// Package fakeportal serves listing and detail pages shaped like the ones of
+// the supported portals, so the whole crawl can be run against a local
+// server instead of the real sites, e.g. in CI.
+//
+//	s := fakeportal.New(fakeportal.Options{Portal: fakeportal.DunaHouse, Listings: listings})
+//	defer s.Close()
+//	crawlers.SetPortalBaseUrl(fakeportal.DunaHouse, s.BaseUrl())
+package fakeportal
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IngatlanCom = "ingatlan.com"
+	DunaHouse   = "dunahouse"
+)
+
+const defaultPerPage = 20
+
+// Listing is a single property served by the fake portal.
+type Listing struct {
+	Id      string // numeric for ingatlan.com, e.g. "H123456" for dunahouse
+	Address string
+	Price   float64 // M Ft
+	Area    int     // left off the page when zero, like on plots
+	Rooms   int
+
+	Status int           // status of the detail page, 200 when zero
+	Delay  time.Duration // the detail page is answered this late
+}
+
+// Options configure a fake portal.
+type Options struct {
+	Portal   string // IngatlanCom or DunaHouse
+	Listings []Listing
+	PerPage  int // listings on a listing page, 20 when zero
+
+	// Throttle answers the first Throttle requests of every detail page with
+	// 429 and "Retry-After: 0", like a portal enforcing its rate limit.
+	Throttle int
+}
+
+// Request is a request received by the fake portal.
+type Request struct {
+	Path string
+	At   time.Time
+}
+
+// Server is a running fake portal.
+type Server struct {
+	*httptest.Server
+	opts Options
+
+	mu       sync.Mutex
+	requests []Request
+	hits     map[string]int
+}
+
+// New starts a fake portal, Close it when done.
+func New(o Options) *Server {
+	if o.PerPage <= 0 {
+		o.PerPage = defaultPerPage
+	}
+	s := &Server{opts: o, hits: make(map[string]int)}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// BaseUrl is the address to crawl instead of the real portal.
+func (s *Server) BaseUrl() string {
+	return s.URL + "/"
+}
+
+// Pages returns the number of listing pages of a search.
+func (s *Server) Pages() int {
+	pages := (len(s.opts.Listings) + s.opts.PerPage - 1) / s.opts.PerPage
+	if pages == 0 {
+		return 1
+	}
+	return pages
+}
+
+// Link returns the link of the listing as found on the listing pages.
+func (s *Server) Link(l Listing) string {
+	if s.opts.Portal == DunaHouse {
+		return "/elado-ingatlan/haz/budapest/" + l.Id
+	}
+	return "/" + l.Id
+}
+
+// Requests returns the requests received so far in the order of arrival.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// record registers the request and returns how many times its path was requested.
+func (s *Server) record(r *http.Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Path: r.URL.RequestURI(), At: time.Now()})
+	s.hits[r.URL.RequestURI()]++
+	return s.hits[r.URL.RequestURI()]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hits := s.record(r)
+
+	page, isListingPage := s.listingPage(r)
+	if isListingPage {
+		s.serveListingPage(w, r, page)
+		return
+	}
+
+	l, ok := s.listing(path.Base(r.URL.Path))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if hits <= s.opts.Throttle {
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	select {
+	case <-time.After(l.Delay):
+	case <-r.Context().Done():
+		return
+	}
+	if l.Status != 0 && l.Status != http.StatusOK {
+		http.Error(w, http.StatusText(l.Status), l.Status)
+		return
+	}
+	s.serveDetailPage(w, l)
+}
+
+var dunaHousePageRegexp = regexp.MustCompile(`/oldal-(\d+)$`)
+
+// listingPage tells whether the request is for a listing page and which one.
+func (s *Server) listingPage(r *http.Request) (int, bool) {
+	switch s.opts.Portal {
+	case IngatlanCom:
+		if !strings.HasPrefix(r.URL.Path, "/lista/") {
+			return 0, false
+		}
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
+		}
+		return page, true
+	case DunaHouse:
+		if !strings.Contains(r.URL.Path, "/-/") {
+			return 0, false
+		}
+		if m := dunaHousePageRegexp.FindStringSubmatch(r.URL.Path); m != nil {
+			page, _ := strconv.Atoi(m[1])
+			return page, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func (s *Server) listing(id string) (Listing, bool) {
+	for _, l := range s.opts.Listings {
+		if l.Id == id {
+			return l, true
+		}
+	}
+	return Listing{}, false
+}
+
+type listingPageData struct {
+	Page, Pages int
+	PageLinks   []pageLink
+	Links       []string
+}
+
+type pageLink struct {
+	Href   string
+	Number int
+}
+
+func (s *Server) serveListingPage(w http.ResponseWriter, r *http.Request, page int) {
+	if page < 1 || page > s.Pages() {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := listingPageData{Page: page, Pages: s.Pages()}
+	from := (page - 1) * s.opts.PerPage
+	for i := from; i < from+s.opts.PerPage && i < len(s.opts.Listings); i++ {
+		data.Links = append(data.Links, s.Link(s.opts.Listings[i]))
+	}
+
+	tmpl := ingatlanComListingPage
+	if s.opts.Portal == DunaHouse {
+		tmpl = dunaHouseListingPage
+		queryPath := dunaHousePageRegexp.ReplaceAllString(r.URL.Path, "")
+		for i := 1; i <= data.Pages; i++ {
+			data.PageLinks = append(data.PageLinks, pageLink{Href: fmt.Sprintf("%s/oldal-%d", queryPath, i), Number: i})
+		}
+	}
+	render(w, tmpl, data)
+}
+
+type detailPageData struct {
+	Listing
+	PriceText string
+}
+
+func (s *Server) serveDetailPage(w http.ResponseWriter, l Listing) {
+	data := detailPageData{Listing: l, PriceText: strings.Replace(strconv.FormatFloat(l.Price, 'f', -1, 64), ".", ",", 1)}
+	if s.opts.Portal == DunaHouse {
+		render(w, dunaHouseDetailPage, data)
+		return
+	}
+	render(w, ingatlanComDetailPage, data)
+}
+
+func render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// The markup is the smallest subset of the real pages the extractors rely on.
+var (
+	ingatlanComListingPage = template.Must(template.New("ingatlan.com listing").Parse(`<html>
+  <body>
+{{- range .Links}}
+    <a class="listing__link js-listing-active-area" href="{{.}}">hirdetés</a>
+{{- end}}
+    <div class="pagination">
+      <div class="pagination__page-number">{{.Page}} / {{.Pages}} oldal</div>
+    </div>
+  </body>
+</html>
+`))
+
+	ingatlanComDetailPage = template.Must(template.New("ingatlan.com detail").Parse(`<html>
+  <body>
+    <h1 class="address">{{.Address}}</h1>
+    <div class="parameters">
+      <div class="parameter">
+        <a href="/hitel">Hitelre van szükséged? Kalkulálj!</a>
+        <div class="parameterValues">
+          <span>{{.PriceText}} M Ft</span>
+        </div>
+      </div>
+{{- if .Area}}
+      <div class="parameter">
+        <div class="parameterTitle">Alapterület</div>
+        <div class="parameterValues">
+          <span>{{.Area}} m2</span>
+        </div>
+      </div>
+{{- end}}
+      <div class="parameter">
+        <div class="parameterTitle">Szobák</div>
+        <div class="parameterValues">
+          <span>{{.Rooms}}</span>
+        </div>
+      </div>
+    </div>
+    <dl>
+      <div>
+        <dt class="parameterName">Ingatlan állapota</dt>
+        <dd class="parameterValue">jó</dd>
+      </div>
+    </dl>
+  </body>
+</html>
+`))
+
+	dunaHouseListingPage = template.Must(template.New("dunahouse listing").Parse(`<html>
+  <body>
+{{- range .Links}}
+    <a class="listEstateWithPicOnPicture" href="{{.}}">
+      <img src="kep.jpg">
+    </a>
+{{- end}}
+    <ul class="pagination">
+{{- range .PageLinks}}
+      <li><a href="{{.Href}}">{{.Number}}</a></li>
+{{- end}}
+    </ul>
+  </body>
+</html>
+`))
+
+	dunaHouseDetailPage = template.Must(template.New("dunahouse detail").Parse(`<html>
+  <body>
+    <ul class="main-info">
+      <li>
+        <span>Ár</span>
+        <div class="value">
+          <b>{{.PriceText}} M Ft</b>
+        </div>
+      </li>
+{{- if .Area}}
+      <li>
+        <span>Méret</span>
+        <div class="value">{{.Area}}m2</div>
+      </li>
+{{- end}}
+      <li>
+        <span>Szoba</span>
+        <div class="value">{{.Rooms}} szoba</div>
+      </li>
+    </ul>
+    <div class="row table-list-style">
+      <div class="col-xs-6">Cím:</div>
+      <div class="col-xs-6">{{.Address}}</div>
+    </div>
+  </body>
+</html>
+`))
+)
@@ -0,0 +1,159 @@
+package crawlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JsonFileStore keeps every listing in memory and persists them into a
+// single json file. The file is replaced atomically on Flush and Close if
+// anything changed.
+type JsonFileStore struct {
+	path string
+
+	mu       sync.Mutex
+	listings map[ListingKey]StoredListing
+	runs     []CrawlRun
+	dirty    bool
+}
+
+type jsonFileStoreContent struct {
+	Runs     []CrawlRun      `json:"runs"`
+	Listings []StoredListing `json:"listings"`
+}
+
+// OpenJsonFileStore loads the store from path, a missing file is treated as an empty store.
+func OpenJsonFileStore(path string) (*JsonFileStore, error) {
+	s := &JsonFileStore{
+		path:     path,
+		listings: make(map[ListingKey]StoredListing),
+	}
+
+	file, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content jsonFileStoreContent
+	if err := json.Unmarshal(file, &content); err != nil {
+		return nil, err
+	}
+	migratedKeys := make(map[ListingKey]ListingKey)
+	for _, l := range content.Listings {
+		if migrated, ok := migrateUrlKey(l); ok {
+			migratedKeys[l.Key] = migrated.Key
+			l = migrated
+			s.dirty = true
+		}
+		s.listings[l.Key] = l
+	}
+	for _, run := range content.Runs {
+		for _, keys := range [][]ListingKey{run.Keys, run.FailedKeys} {
+			for i, key := range keys {
+				if newKey, ok := migratedKeys[key]; ok {
+					keys[i] = newKey
+				}
+			}
+		}
+	}
+	s.runs = content.Runs
+
+	return s, nil
+}
+
+func (s *JsonFileStore) Upsert(key ListingKey, p PropertyInfo, seenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listings[key] = upsertListing(s.listings[key], key, p, seenAt)
+	s.dirty = true
+	return nil
+}
+
+func (s *JsonFileStore) Get(key ListingKey) (StoredListing, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.listings[key]
+	return l, ok, nil
+}
+
+func (s *JsonFileStore) Listings() ([]StoredListing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listings := make([]StoredListing, 0, len(s.listings))
+	for _, l := range s.listings {
+		listings = append(listings, l)
+	}
+	sortListings(listings)
+	return listings, nil
+}
+
+func (s *JsonFileStore) AddRun(run CrawlRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = append(s.runs, run)
+	sort.SliceStable(s.runs, func(i, j int) bool {
+		return s.runs[i].StartedAt.Before(s.runs[j].StartedAt)
+	})
+	s.dirty = true
+	return nil
+}
+
+func (s *JsonFileStore) Runs() ([]CrawlRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]CrawlRun(nil), s.runs...), nil
+}
+
+// Flush writes the content of the store to disk.
+func (s *JsonFileStore) Flush() error {
+	listings, _ := s.Listings()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(jsonFileStoreContent{Runs: s.runs, Listings: listings}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
+
+func (s *JsonFileStore) Close() error {
+	return s.Flush()
+}
@@ -0,0 +1,153 @@
+package crawlers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterConfig narrows the crawl results on the extracted fields, beyond
+// what the search urls of the portals can express. Zero values disable a rule.
+type FilterConfig struct {
+	MinLotArea          int                 `json:"min_telekterület"`
+	MinRooms            int                 `json:"min_szobák"`
+	BuiltAfter          int                 `json:"építés_után"`
+	Conditions          []ConditionCategory `json:"állapotok"`
+	Heatings            []HeatingType       `json:"fűtések"`
+	MaxPricePerSqrMeter float64             `json:"max_négyzetméter_ár"`
+	ParkingRequired     bool                `json:"parkolás_kötelező"`
+
+	// By default a listing is kept when it does not show the value a rule checks.
+	DropUnknown bool `json:"ismeretlen_kiszűrése"`
+}
+
+type ruleResult int
+
+const (
+	rulePassed ruleResult = iota
+	ruleFailed
+	ruleUnknown
+)
+
+type filterRule struct {
+	name  string
+	check func(p PropertyInfo) ruleResult
+}
+
+// FilterReport tells how many listings each rule removed. A listing is
+// only counted for the first rule it failed.
+type FilterReport struct {
+	Total   int
+	Kept    int
+	Removed map[string]int
+	rules   []string
+}
+
+func (r FilterReport) String() string {
+	var parts []string
+	for _, name := range r.rules {
+		if r.Removed[name] != 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", name, r.Removed[name]))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("kept all %d listing(s)", r.Total)
+	}
+	return fmt.Sprintf("kept %d of %d listing(s), removed by %s", r.Kept, r.Total, strings.Join(parts, ", "))
+}
+
+func (fc FilterConfig) rules() []filterRule {
+	var rules []filterRule
+
+	if fc.MinLotArea > 0 {
+		rules = append(rules, filterRule{"min_telekterület", func(p PropertyInfo) ruleResult {
+			return knownIf(p.LotArea != 0, p.LotArea >= fc.MinLotArea)
+		}})
+	}
+	if fc.MinRooms > 0 {
+		rules = append(rules, filterRule{"min_szobák", func(p PropertyInfo) ruleResult {
+			return knownIf(p.NumOfRooms > 0, p.NumOfRooms >= fc.MinRooms)
+		}})
+	}
+	if fc.BuiltAfter > 0 {
+		rules = append(rules, filterRule{"építés_után", func(p PropertyInfo) ruleResult {
+			if p.BuildYearFrom != 0 {
+				return knownIf(true, p.BuildYearFrom > fc.BuiltAfter)
+			}
+			// only an upper bound is known, e.g. "1950 előtt"
+			if p.BuildYearTo != 0 && p.BuildYearTo <= fc.BuiltAfter {
+				return ruleFailed
+			}
+			return ruleUnknown
+		}})
+	}
+	if len(fc.Conditions) != 0 {
+		rules = append(rules, filterRule{"állapotok", func(p PropertyInfo) ruleResult {
+			found := false
+			for _, c := range fc.Conditions {
+				found = found || c == p.ConditionCategory
+			}
+			return knownIf(p.ConditionCategory != ConditionUnknown, found)
+		}})
+	}
+	if len(fc.Heatings) != 0 {
+		rules = append(rules, filterRule{"fűtések", func(p PropertyInfo) ruleResult {
+			found := false
+			for _, h := range fc.Heatings {
+				found = found || h == p.HeatingType
+			}
+			return knownIf(p.HeatingType != HeatingUnknown, found)
+		}})
+	}
+	if fc.MaxPricePerSqrMeter > 0 {
+		rules = append(rules, filterRule{"max_négyzetméter_ár", func(p PropertyInfo) ruleResult {
+			return knownIf(p.PricePerSqrMeter > 0, p.PricePerSqrMeter <= fc.MaxPricePerSqrMeter)
+		}})
+	}
+	if fc.ParkingRequired {
+		rules = append(rules, filterRule{"parkolás_kötelező", func(p PropertyInfo) ruleResult {
+			return knownIf(p.ParkingType != ParkingUnknown, p.ParkingType != ParkingStreet && p.ParkingType != ParkingNone)
+		}})
+	}
+
+	return rules
+}
+
+func knownIf(known, passed bool) ruleResult {
+	if !known {
+		return ruleUnknown
+	}
+	if passed {
+		return rulePassed
+	}
+	return ruleFailed
+}
+
+// FilterProperties returns the properties passing every rule of the config.
+func FilterProperties(props []PropertyInfo, fc FilterConfig) ([]PropertyInfo, FilterReport) {
+	rules := fc.rules()
+	report := FilterReport{Total: len(props), Removed: make(map[string]int)}
+	for _, r := range rules {
+		report.rules = append(report.rules, r.name)
+	}
+
+	var kept []PropertyInfo
+	for _, p := range props {
+		removedBy := ""
+		for _, r := range rules {
+			res := r.check(p)
+			if res == ruleFailed || res == ruleUnknown && fc.DropUnknown {
+				removedBy = r.name
+				break
+			}
+		}
+
+		if len(removedBy) != 0 {
+			report.Removed[removedBy]++
+			continue
+		}
+		kept = append(kept, p)
+	}
+
+	report.Kept = len(kept)
+	return kept, report
+}
@@ -0,0 +1,122 @@
+package crawlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	FixtureRecord = "record" // fetch from the network and save every response
+	FixtureReplay = "replay" // serve the saved responses, never touch the network
+)
+
+// Fixture is a saved http response.
+type Fixture struct {
+	Url     string      `json:"url"`
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+var fixtureNameRegexp = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// FixturePath returns the file the response of url is saved to in dir: the
+// readable part of the url followed by a hash telling similar urls apart.
+func FixturePath(dir, url string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	name = strings.Trim(fixtureNameRegexp.ReplaceAllString(name, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.json", name, hex.EncodeToString(sum[:4])))
+}
+
+// ReadFixture loads the response saved for url.
+func ReadFixture(dir, url string) (Fixture, error) {
+	data, err := ioutil.ReadFile(FixturePath(dir, url))
+	if err != nil {
+		return Fixture{}, fmt.Errorf("no fixture for '%s': %w", url, err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Fixture{}, fmt.Errorf("fixture of '%s' is invalid: %w", url, err)
+	}
+	return f, nil
+}
+
+// WriteFixture saves the response of url into dir.
+func WriteFixture(dir string, f Fixture) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(FixturePath(dir, f.Url), data, 0644)
+}
+
+// recordingTransport saves every response passing through it.
+type recordingTransport struct {
+	next http.RoundTripper
+	dir  string
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	f := Fixture{Url: req.URL.String(), Status: resp.StatusCode, Headers: resp.Header, Body: string(body)}
+	if err := WriteFixture(t.dir, f); err != nil {
+		return nil, fmt.Errorf("could not save fixture of '%s': %w", f.Url, err)
+	}
+	return resp, nil
+}
+
+// replayTransport serves the saved responses, urls without one fail like an
+// unreachable host would.
+type replayTransport struct {
+	dir string
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f, err := ReadFixture(t.dir, req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	headers := f.Headers
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          ioutil.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
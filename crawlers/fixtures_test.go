@@ -0,0 +1,49 @@
+package crawlers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecordedFixturesReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Path)
+	}))
+	defer server.Close()
+
+	url := server.URL + "/lista/elado+haz"
+	recorder := NewFetcher(FetcherConfig{FixtureMode: FixtureRecord, FixturesDir: dir})
+	recorded := fetchBody(t, recorder, url)
+	server.Close()
+
+	replayer := NewFetcher(FetcherConfig{FixtureMode: FixtureReplay, FixturesDir: dir})
+	if replayed := fetchBody(t, replayer, url); replayed != recorded {
+		t.Errorf("replayed body %q, recorded %q", replayed, recorded)
+	}
+
+	if _, err := replayer.Get(context.Background(), server.URL+"/nincs-felveve"); err == nil {
+		t.Error("expected an error for a url without fixture")
+	}
+}
+
+func fetchBody(t *testing.T, f *Fetcher, url string) string {
+	t.Helper()
+
+	resp, err := f.Get(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
@@ -0,0 +1,84 @@
+package crawlers
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// GeoLocationExtractor picks up the coordinates of the property from the
+// common places pages put them: the place:location meta tags and the
+// data-lat/data-lng attributes of map widgets. Portals without a map leave
+// the coordinates at 0.
+type GeoLocationExtractor struct {
+	Latitude, Longitude float64
+}
+
+func (e *GeoLocationExtractor) Predicate(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "property", "data-lat", "data-latitude", "data-lng", "data-longitude":
+			return true
+		}
+	}
+	return false
+}
+
+func (e *GeoLocationExtractor) ProcessNode(n *html.Node) {
+	attrs := make(map[string]string, len(n.Attr))
+	for _, attr := range n.Attr {
+		attrs[attr.Key] = strings.TrimSpace(attr.Val)
+	}
+
+	if n.Data == "meta" {
+		switch attrs["property"] {
+		case "place:location:latitude":
+			e.Latitude = parseCoordinate(attrs["content"], e.Latitude)
+		case "place:location:longitude":
+			e.Longitude = parseCoordinate(attrs["content"], e.Longitude)
+		}
+		return
+	}
+
+	for _, key := range []string{"data-lat", "data-latitude"} {
+		if v, ok := attrs[key]; ok {
+			e.Latitude = parseCoordinate(v, e.Latitude)
+		}
+	}
+	for _, key := range []string{"data-lng", "data-longitude"} {
+		if v, ok := attrs[key]; ok {
+			e.Longitude = parseCoordinate(v, e.Longitude)
+		}
+	}
+}
+
+// Done reports whether both coordinates were found, the first place having them wins.
+func (e *GeoLocationExtractor) Done() bool {
+	return e.Latitude != 0 && e.Longitude != 0
+}
+
+func (e *GeoLocationExtractor) AddInfoIntoProp(p *PropertyInfo) {
+	if e.Latitude == 0 || e.Longitude == 0 {
+		return
+	}
+	p.Latitude = e.Latitude
+	p.Longitude = e.Longitude
+}
+
+// parseCoordinate returns the parsed coordinate, or fallback when s is not one.
+func parseCoordinate(s string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil || v == 0 {
+		return fallback
+	}
+	return v
+}
+
+// HasCoordinates reports whether the location of the property is known.
+func (pi PropertyInfo) HasCoordinates() bool {
+	return pi.Latitude != 0 && pi.Longitude != 0
+}
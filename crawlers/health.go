@@ -0,0 +1,257 @@
+package crawlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultMaxFillRateDrop = 30.0
+	defaultMinListings     = 5
+)
+
+// defaultMinFillRates are checked when the config does not list any. A
+// portal redesign usually shows up as missing prices and areas first.
+var defaultMinFillRates = map[string]float64{"price": 50, "house_area": 50}
+
+// HealthConfig sets when the extraction of a portal is considered broken.
+// Rates are percentages of the listings of the portal in a run.
+type HealthConfig struct {
+	Disabled bool `json:"kikapcsolva"`
+	// MinFillRates are the lowest accepted fill rates by field, see HealthFieldKeys.
+	MinFillRates map[string]float64 `json:"min_kitöltöttség_százalék"`
+	// MaxDrop is the largest accepted drop of any fill rate compared to the
+	// previous run, in percentage points.
+	MaxDrop float64 `json:"max_visszaesés_százalék"`
+	// Portals with fewer listings in the run are not checked.
+	MinListings int `json:"min_hirdetések"`
+}
+
+func (hc HealthConfig) withDefaults() HealthConfig {
+	if len(hc.MinFillRates) == 0 {
+		hc.MinFillRates = defaultMinFillRates
+	}
+	if hc.MaxDrop <= 0 {
+		hc.MaxDrop = defaultMaxFillRateDrop
+	}
+	if hc.MinListings <= 0 {
+		hc.MinListings = defaultMinListings
+	}
+	return hc
+}
+
+type healthField struct {
+	key    string
+	filled func(p PropertyInfo) bool
+}
+
+// healthFields are the fields every listing is expected to have, keyed like the columns.
+var healthFields = []healthField{
+	{"address", func(p PropertyInfo) bool { return len(p.Address) != 0 }},
+	{"price", func(p PropertyInfo) bool { return p.ListedPrice() > 0 }}, // the rent for rentals
+	{"house_area", func(p PropertyInfo) bool { return p.HouseArea > 0 }},
+	{"rooms", func(p PropertyInfo) bool { return p.NumOfRooms > 0 }},
+	{"condition", func(p PropertyInfo) bool { return len(p.Condition) != 0 }},
+	{"heating", func(p PropertyInfo) bool { return len(p.Heating) != 0 }},
+	{"built_in", func(p PropertyInfo) bool { return len(p.BuiltIn) != 0 }},
+	{"coordinates", PropertyInfo.HasCoordinates},
+}
+
+// HealthFieldKeys returns the fields whose fill rate is tracked.
+func HealthFieldKeys() []string {
+	keys := make([]string, len(healthFields))
+	for i, f := range healthFields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// FillRates describe how many of the listings of a portal had each field.
+type FillRates struct {
+	Listings int                `json:"listings"`
+	Fields   map[string]float64 `json:"fields"` // percentage of the listings having the field
+}
+
+// ExtractionStats counts the filled fields of the extracted listings by
+// portal. It is safe for concurrent use.
+type ExtractionStats struct {
+	mu       sync.Mutex
+	listings map[string]int
+	filled   map[string]map[string]int
+}
+
+func NewExtractionStats() *ExtractionStats {
+	return &ExtractionStats{
+		listings: make(map[string]int),
+		filled:   make(map[string]map[string]int),
+	}
+}
+
+// Add counts a listing of p.Portal. Listings dropped for a missing field
+// must be added too, they are the first sign of a changed layout.
+func (s *ExtractionStats) Add(p PropertyInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listings[p.Portal]++
+	if s.filled[p.Portal] == nil {
+		s.filled[p.Portal] = make(map[string]int)
+	}
+	for _, f := range healthFields {
+		if f.filled(p) {
+			s.filled[p.Portal][f.key]++
+		}
+	}
+}
+
+// FillRates returns the fill rates of every portal with at least one listing.
+func (s *ExtractionStats) FillRates() map[string]FillRates {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rates := make(map[string]FillRates)
+	for portal, n := range s.listings {
+		fr := FillRates{Listings: n, Fields: make(map[string]float64)}
+		for _, f := range healthFields {
+			fr.Fields[f.key] = 100 * float64(s.filled[portal][f.key]) / float64(n)
+		}
+		rates[portal] = fr
+	}
+	return rates
+}
+
+// PreviousFillRates returns the fill rates of every portal from the latest
+// complete run of the same searches that has them, the runs are ordered
+// oldest first. Interrupted runs are skipped like in DiffLastRuns, their
+// rates cover only a part of the listings.
+func PreviousFillRates(runs []CrawlRun, searches []string) map[string]FillRates {
+	previous := make(map[string]FillRates)
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Interrupted || !runs[i].SameSearches(searches) {
+			continue
+		}
+		for portal, fr := range runs[i].FillRates {
+			if _, ok := previous[portal]; !ok {
+				previous[portal] = fr
+			}
+		}
+	}
+	return previous
+}
+
+// HealthProblem is a fill rate that is too low or dropped too much.
+type HealthProblem struct {
+	Portal   string
+	Field    string
+	Rate     float64
+	Previous float64 // only for drops
+	Limit    float64
+	Drop     bool
+}
+
+func (p HealthProblem) String() string {
+	if p.Drop {
+		return fmt.Sprintf("%s: %.0f%% of the listings have '%s', %.0f%% had it in the previous run (accepted drop: %.0f points)",
+			p.Portal, p.Rate, p.Field, p.Previous, p.Limit)
+	}
+	return fmt.Sprintf("%s: %.0f%% of the listings have '%s', expected at least %.0f%%", p.Portal, p.Rate, p.Field, p.Limit)
+}
+
+// LayoutChangedError reports portals whose pages most likely changed so
+// that the extractors no longer find the data.
+type LayoutChangedError struct {
+	Problems []HealthProblem
+}
+
+func (e *LayoutChangedError) Error() string {
+	var portals []string
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		if len(portals) == 0 || portals[len(portals)-1] != p.Portal {
+			portals = append(portals, p.Portal)
+		}
+		lines[i] = p.String()
+	}
+	return fmt.Sprintf("layout of %s likely changed, the extractors need updating:\n  %s",
+		strings.Join(portals, ", "), strings.Join(lines, "\n  "))
+}
+
+// CheckHealth compares the fill rates of the run to the limits of the
+// config and to the previous run. It returns a *LayoutChangedError listing
+// every problem, or nil when the extraction looks fine.
+func CheckHealth(current, previous map[string]FillRates, hc HealthConfig) error {
+	if hc.Disabled {
+		return nil
+	}
+	hc = hc.withDefaults()
+
+	var problems []HealthProblem
+	for _, portal := range sortedPortals(current) {
+		fr := current[portal]
+		if fr.Listings < hc.MinListings {
+			continue
+		}
+
+		for _, f := range healthFields {
+			rate := fr.Fields[f.key]
+			if min, ok := hc.MinFillRates[f.key]; ok && rate < min {
+				problems = append(problems, HealthProblem{Portal: portal, Field: f.key, Rate: rate, Limit: min})
+				continue
+			}
+			prev, ok := previous[portal]
+			if !ok || prev.Listings < hc.MinListings {
+				continue
+			}
+			if prevRate, ok := prev.Fields[f.key]; ok && prevRate-rate > hc.MaxDrop {
+				problems = append(problems, HealthProblem{Portal: portal, Field: f.key, Rate: rate, Previous: prevRate, Limit: hc.MaxDrop, Drop: true})
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &LayoutChangedError{Problems: problems}
+}
+
+// WriteFillRatesAsCsv writes a row for every field of every portal with
+// the rate of the previous run next to it, when there was one.
+func WriteFillRatesAsCsv(w io.Writer, current, previous map[string]FillRates) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Portál", "Mező", "Hirdetések", "Kitöltöttség (%)", "Előző kitöltöttség (%)"}); err != nil {
+		return err
+	}
+
+	for _, portal := range sortedPortals(current) {
+		fr := current[portal]
+		for _, f := range healthFields {
+			prevRate := ""
+			if prev, ok := previous[portal]; ok {
+				if r, ok := prev.Fields[f.key]; ok {
+					prevRate = strconv.FormatFloat(r, 'f', 1, 64)
+				}
+			}
+			record := []string{portal, f.key, strconv.Itoa(fr.Listings), strconv.FormatFloat(fr.Fields[f.key], 'f', 1, 64), prevRate}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func sortedPortals(rates map[string]FillRates) []string {
+	portals := make([]string, 0, len(rates))
+	for p := range rates {
+		portals = append(portals, p)
+	}
+	sort.Strings(portals)
+	return portals
+}
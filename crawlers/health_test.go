@@ -0,0 +1,118 @@
+package crawlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPreviousFillRatesOfTheSameSearches(t *testing.T) {
+	rates := func(price float64) map[string]FillRates {
+		return map[string]FillRates{"ingatlan.com": {Listings: 10, Fields: map[string]float64{"price": price}}}
+	}
+	runs := []CrawlRun{
+		{Searches: []string{"buda"}, FillRates: rates(90)},
+		{Searches: []string{"buda", "pest"}, FillRates: rates(80)},
+		{Searches: []string{"pest"}, FillRates: rates(70)},
+		{Searches: []string{"buda"}, FillRates: rates(10), Interrupted: true},
+	}
+
+	if got := PreviousFillRates(runs, []string{"buda"})["ingatlan.com"].Fields["price"]; got != 90 {
+		t.Errorf("expected the rates of the complete run of the same searches, got %v", got)
+	}
+	if got := PreviousFillRates(runs, []string{"pest", "buda"})["ingatlan.com"].Fields["price"]; got != 80 {
+		t.Errorf("expected the rates of the run of both searches, got %v", got)
+	}
+	if got := PreviousFillRates(runs, []string{"other"}); len(got) != 0 {
+		t.Errorf("expected no rates without a run of the same searches, got %v", got)
+	}
+}
+
+// fillRates returns the rates of a portal having every field in every
+// listing but the given ones.
+func fillRates(listings int, fields map[string]float64) FillRates {
+	fr := FillRates{Listings: listings, Fields: make(map[string]float64)}
+	for _, key := range HealthFieldKeys() {
+		fr.Fields[key] = 100
+	}
+	for key, rate := range fields {
+		fr.Fields[key] = rate
+	}
+	return fr
+}
+
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  FillRates
+		previous *FillRates
+		config   HealthConfig
+		problems []HealthProblem
+	}{
+		{
+			name:    "healthy",
+			current: fillRates(10, nil),
+		},
+		{
+			name:     "below the minimum fill rate",
+			current:  fillRates(10, map[string]float64{"house_area": 40}),
+			problems: []HealthProblem{{Portal: "ingatlan.com", Field: "house_area", Rate: 40, Limit: 50}},
+		},
+		{
+			name:     "configured minimums replace the defaults",
+			current:  fillRates(10, map[string]float64{"house_area": 40, "heating": 70}),
+			config:   HealthConfig{MinFillRates: map[string]float64{"heating": 80}},
+			problems: []HealthProblem{{Portal: "ingatlan.com", Field: "heating", Rate: 70, Limit: 80}},
+		},
+		{
+			name:     "dropped since the previous run",
+			current:  fillRates(10, map[string]float64{"condition": 50}),
+			previous: &FillRates{Listings: 10, Fields: map[string]float64{"condition": 90}},
+			problems: []HealthProblem{{Portal: "ingatlan.com", Field: "condition", Rate: 50, Previous: 90, Limit: 30, Drop: true}},
+		},
+		{
+			name:     "drop within the accepted one",
+			current:  fillRates(10, map[string]float64{"condition": 50}),
+			previous: &FillRates{Listings: 10, Fields: map[string]float64{"condition": 80}},
+		},
+		{
+			name:    "too few listings to check",
+			current: fillRates(4, map[string]float64{"price": 0, "house_area": 0}),
+		},
+		{
+			name:     "previous run with too few listings",
+			current:  fillRates(10, map[string]float64{"condition": 0}),
+			previous: &FillRates{Listings: 4, Fields: map[string]float64{"condition": 100}},
+		},
+		{
+			name:    "disabled",
+			current: fillRates(10, map[string]float64{"price": 0}),
+			config:  HealthConfig{Disabled: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := map[string]FillRates{"ingatlan.com": tt.current}
+			previous := map[string]FillRates{}
+			if tt.previous != nil {
+				previous["ingatlan.com"] = *tt.previous
+			}
+
+			err := CheckHealth(current, previous, tt.config)
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Errorf("expected no problems, got: %s", err)
+				}
+				return
+			}
+			var layoutErr *LayoutChangedError
+			if !errors.As(err, &layoutErr) {
+				t.Fatalf("expected a *LayoutChangedError, got: %v", err)
+			}
+			if !reflect.DeepEqual(layoutErr.Problems, tt.problems) {
+				t.Errorf("expected problems\n%+v\ngot\n%+v", tt.problems, layoutErr.Problems)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package crawlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -14,6 +15,13 @@ type HtmlNodeProcessor interface {
 	ProcessNode(n *html.Node)
 }
 
+// DoneSignaler is implemented by the processors that can tell when they have
+// seen everything they need. The traversal stops handing them nodes from then
+// on, and stops altogether once every processor is done.
+type DoneSignaler interface {
+	Done() bool
+}
+
 type LinkExtractor interface {
 	HtmlNodeProcessor
 	GetLinks() []string
@@ -30,19 +38,13 @@ type PageDataExtractor interface {
 	AddInfoIntoProp(prop *PropertyInfo)
 }
 
-func CollectInfoFromPropertyPage(url string, propChan chan<- PropertyInfo, extractors ...PageDataExtractor) {
-	resp, err := sendGetRequest(url)
-	if err != nil {
-		log.Printf("error when opening '%s': '%s'", url, err)
-	}
-	if resp.StatusCode == 404 {
-		log.Printf("could not find page '%s'", url)
-	}
-	defer resp.Body.Close()
-
-	doc, err := html.Parse(resp.Body)
+// CollectInfoFromPropertyPage fetches the property page at url and runs the
+// extractors on it. The returned error is a *CrawlError unless ctx was cancelled,
+// with IsIncomplete the property is usable despite the error.
+func CollectInfoFromPropertyPage(ctx context.Context, url string, extractors ...PageDataExtractor) (PropertyInfo, error) {
+	doc, err := fetchHtmlDocument(ctx, url)
 	if err != nil {
-		log.Printf("error parsing response body: '%s'\n", err)
+		return PropertyInfo{}, err
 	}
 
 	nodeProcessors := convertPageDataExtractorsToHtmlNodeProcessors(extractors...)
@@ -54,7 +56,57 @@ func CollectInfoFromPropertyPage(url string, propChan chan<- PropertyInfo, extra
 		extractor.AddInfoIntoProp(&propInfo)
 	}
 
-	propChan <- propInfo
+	if propInfo.MonthlyRent > 0 {
+		propInfo.Transaction = Rent
+	}
+	if field := firstMissingRequiredField(propInfo); len(field) != 0 {
+		return propInfo, newMissingFieldError(url, field)
+	}
+	if propInfo.HouseArea <= 0 {
+		return propInfo, newIncompleteError(url, "HouseArea")
+	}
+
+	return propInfo, nil
+}
+
+// fetchHtmlDocument downloads and parses the page at url. Errors are
+// reported as *CrawlError, or as the context's error on cancellation.
+func fetchHtmlDocument(ctx context.Context, url string) (*html.Node, error) {
+	resp, err := sendGetRequest(ctx, url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, newNetworkError(url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHttpStatusError(url, resp.StatusCode)
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if ctx.Err() != nil {
+		// the body may have been cut short, the page is not worth keeping
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, newParseError(url, err)
+	}
+
+	return doc, nil
+}
+
+// firstMissingRequiredField returns the name of the first field without
+// which a property is not worth keeping, or "" if all of them are present.
+func firstMissingRequiredField(p PropertyInfo) string {
+	if p.ListedPrice() <= 0 {
+		if p.IsRental() {
+			return "MonthlyRent"
+		}
+		return "Price"
+	}
+	return ""
 }
 
 func convertPageDataExtractorsToHtmlNodeProcessors(extractors ...PageDataExtractor) []HtmlNodeProcessor {
@@ -65,18 +117,7 @@ func convertPageDataExtractorsToHtmlNodeProcessors(extractors ...PageDataExtract
 	return processors
 }
 func traverseHtmlTreeAndExtractString(doc *html.Node, extractor HtmlNodeProcessor) {
-	var f func(n *html.Node)
-
-	f = func(n *html.Node) {
-		if extractor.Predicate(n) {
-			extractor.ProcessNode(n)
-		}
-
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
-	}
-	f(doc)
+	traverseHtmlTreeAndExecuteExtractors(doc, extractor)
 }
 
 func findHrefAttribute(n *html.Node) string {
@@ -112,11 +153,21 @@ func getChildsParamNameAndValue(n *html.Node) (string, string, error) {
 		return "", "", errors.New("parameter name or value not found")
 	}
 
-	return strings.TrimSpace(name.FirstChild.Data), strings.TrimSpace(value.FirstChild.Data), nil
+	return nodeText(name), nodeText(value), nil
 }
 
 func isNodeTypeOf(n *html.Node, t string) bool {
-	return n.Type == html.ElementNode && n.Data == t
+	return n != nil && n.Type == html.ElementNode && n.Data == t
+}
+
+// firstElementChild skips the whitespace between the tags of indented markup.
+func firstElementChild(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			return c
+		}
+	}
+	return nil
 }
 func isLinkNode(n *html.Node) bool {
 	return isNodeTypeOf(n, "a")
@@ -146,46 +197,76 @@ func findParameterValuesClassAmongSiblings(n *html.Node) *html.Node {
 }
 
 func isPriceHeaderNode(n *html.Node) bool {
-	return isLinkNode(n) && n.FirstChild != nil && strings.TrimSpace(n.FirstChild.Data) == "Hitelre van szükséged? Kalkulálj!"
+	return isLinkNode(n) && nodeText(n) == "Hitelre van szükséged? Kalkulálj!"
 }
 
 func isNodeParameterTitle(n *html.Node) bool {
 	return isDivNode(n) && doesClassAttrContainsVal(n, "parameterTitle")
 }
 
+// traverseHtmlTreeAndExecuteExtractors walks the tree once and hands every
+// node to each of the extractors whose predicate matches it, in document order.
 func traverseHtmlTreeAndExecuteExtractors(root *html.Node, extractors ...HtmlNodeProcessor) {
-	for _, extractor := range extractors {
-		traverseHtmlTreeAndExtractString(root, extractor)
+	active := make([]HtmlNodeProcessor, 0, len(extractors))
+	for _, e := range extractors {
+		if !isDone(e) {
+			active = append(active, e)
+		}
+	}
+
+	// walk returns false once every extractor is done
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		for i := 0; i < len(active); i++ {
+			e := active[i]
+			if !e.Predicate(n) {
+				continue
+			}
+			e.ProcessNode(n)
+			if isDone(e) {
+				active = append(active[:i], active[i+1:]...)
+				i--
+			}
+		}
+		if len(active) == 0 {
+			return false
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if !walk(c) {
+				return false
+			}
+		}
+		return true
 	}
+	walk(root)
 }
 
-func CollectPropertyLinksForQuery(url string, le LinkExtractor, lpe ListingPagesExtractor) error {
-	err := extractListingPagesInfo(lpe, url)
+func isDone(p HtmlNodeProcessor) bool {
+	ds, ok := p.(DoneSignaler)
+	return ok && ds.Done()
+}
+
+func CollectPropertyLinksForQuery(ctx context.Context, url string, le LinkExtractor, lpe ListingPagesExtractor) error {
+	err := extractListingPagesInfo(ctx, lpe, url)
 	if err != nil {
 		return err
 	}
 
 	urlTemplate := url + lpe.NextPageFormat()
-	extractLinksFromListingPages(urlTemplate, lpe, le)
-
-	return nil
+	return extractLinksFromListingPages(ctx, urlTemplate, lpe, le)
 }
 
-func extractLinksFromListingPages(urlTemplate string, lpe ListingPagesExtractor, le LinkExtractor) error {
+func extractLinksFromListingPages(ctx context.Context, urlTemplate string, lpe ListingPagesExtractor, le LinkExtractor) error {
 	for i := 1; i <= lpe.MaxPageNumber(); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		queryUrl := fmt.Sprintf(urlTemplate, i)
 		log.Printf("reading from url %s\n", queryUrl)
 
-		resp, err := sendGetRequest(queryUrl)
-		if err != nil {
-			return fmt.Errorf("error when requesting content from %s: '%s'", queryUrl, err)
-		}
-		if resp.StatusCode == 404 {
-			log.Printf("stopped at page %d\n", i)
-		}
-		defer resp.Body.Close()
-
-		doc, err := html.Parse(resp.Body)
+		doc, err := fetchHtmlDocument(ctx, queryUrl)
 		if err != nil {
 			return err
 		}
@@ -196,18 +277,8 @@ func extractLinksFromListingPages(urlTemplate string, lpe ListingPagesExtractor,
 	return nil
 }
 
-func extractListingPagesInfo(lpe ListingPagesExtractor, url string) error {
-
-	resp, err := sendGetRequest(url)
-	if err != nil {
-		return fmt.Errorf("error when requesting content from %s: '%s'", url, err)
-	}
-	if resp.StatusCode == 404 {
-		log.Printf("could not find page at url: %s\n", url)
-	}
-	defer resp.Body.Close()
-
-	doc, err := html.Parse(resp.Body)
+func extractListingPagesInfo(ctx context.Context, lpe ListingPagesExtractor, url string) error {
+	doc, err := fetchHtmlDocument(ctx, url)
 	if err != nil {
 		return err
 	}
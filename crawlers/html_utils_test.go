@@ -0,0 +1,185 @@
+package crawlers
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// traverseOncePerExtractor walks the whole tree for every extractor, as the
+// crawler did before the single pass traversal. It is the baseline of the benchmarks.
+func traverseOncePerExtractor(root *html.Node, extractors ...HtmlNodeProcessor) {
+	for _, e := range extractors {
+		var f func(n *html.Node)
+		f = func(n *html.Node) {
+			if e.Predicate(n) {
+				e.ProcessNode(n)
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				f(c)
+			}
+		}
+		f(root)
+	}
+}
+
+type savedPage struct {
+	name   string
+	portal Portal
+	doc    *html.Node
+}
+
+// paddingBlock stands for the markup real pages have around the data: menus,
+// recommendations, footers. Saved pages are trimmed to the data.
+const paddingBlock = `<div class="card"><div class="card__body"><p class="text">Ajánlott ingatlan a környéken</p>` +
+	`<a class="card__link" href="/ajanlo">Megnézem</a><ul><li><span>Ár</span> megegyezés szerint</li></ul></div></div>`
+
+// savedDetailPages parses the saved detail pages, padded with the given
+// number of unrelated blocks before and after the data.
+func savedDetailPages(tb testing.TB, padding int) []savedPage {
+	tb.Helper()
+
+	var pages []savedPage
+	for _, dp := range detailPages {
+		p, err := GetPortal(dp.portal)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		f, err := ReadFixture(fixturesDir, AbsolutePortalUrl(p, dp.link))
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		pad := strings.Repeat(paddingBlock, padding/2)
+		body := strings.Replace(f.Body, "<body>", "<body>"+pad, 1)
+		body = strings.Replace(body, "</body>", pad+"</body>", 1)
+		doc, err := html.Parse(strings.NewReader(body))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		pages = append(pages, savedPage{name: dp.golden, portal: p, doc: doc})
+	}
+	return pages
+}
+
+func extractProperty(doc *html.Node, extractors []PageDataExtractor, traverse func(*html.Node, ...HtmlNodeProcessor)) PropertyInfo {
+	traverse(doc, convertPageDataExtractorsToHtmlNodeProcessors(extractors...)...)
+	var p PropertyInfo
+	for _, e := range extractors {
+		e.AddInfoIntoProp(&p)
+	}
+	return p
+}
+
+func TestSinglePassExtractsTheSameAsOnePassPerExtractor(t *testing.T) {
+	for _, page := range savedDetailPages(t, 20) {
+		single := extractProperty(page.doc, page.portal.NewPageDataExtractors(), traverseHtmlTreeAndExecuteExtractors)
+		perExtractor := extractProperty(page.doc, page.portal.NewPageDataExtractors(), traverseOncePerExtractor)
+		if single != perExtractor {
+			t.Errorf("%s: single pass extracted\n%+v\nseparate passes\n%+v", page.name, single, perExtractor)
+		}
+	}
+}
+
+// TestParametersSplitOverSeveralBlocks checks that no extractor stops after
+// the first block of parameters, the portals may spread them over several.
+func TestParametersSplitOverSeveralBlocks(t *testing.T) {
+	splits := []struct {
+		golden, before, split string
+	}{
+		{"ingatlan.com_sale", "<div class=\"parameter\">\n        <div class=\"parameterTitle\">Szobák</div>", `</div><div class="parameters">`},
+		{"dunahouse_sale", `<div class="col-xs-6">Fűtés:</div>`, `</div><div class="row table-list-style">`},
+	}
+
+	for _, sp := range splits {
+		t.Run(sp.golden, func(t *testing.T) {
+			var page savedPage
+			for _, p := range savedDetailPages(t, 0) {
+				if p.name == sp.golden {
+					page = p
+				}
+			}
+			var body strings.Builder
+			if err := html.Render(&body, page.doc); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), sp.before) {
+				t.Fatalf("the saved page has no '%s' to split at", sp.before)
+			}
+			doc, err := html.Parse(strings.NewReader(strings.Replace(body.String(), sp.before, sp.split+sp.before, 1)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			whole := extractProperty(page.doc, page.portal.NewPageDataExtractors(), traverseHtmlTreeAndExecuteExtractors)
+			split := extractProperty(doc, page.portal.NewPageDataExtractors(), traverseHtmlTreeAndExecuteExtractors)
+			if split != whole {
+				t.Errorf("extracted from split blocks\n%+v\nfrom a single block\n%+v", split, whole)
+			}
+
+			rules, err := compileRules(page.portal.Name(), DefaultExtractionRules()[page.portal.Name()])
+			if err != nil {
+				t.Fatal(err)
+			}
+			byRules := extractProperty(doc, []PageDataExtractor{&RuleExtractor{rules: rules}}, traverseHtmlTreeAndExecuteExtractors)
+			if byRules != split {
+				t.Errorf("the rules extracted from split blocks\n%+v\nthe built-in extractors\n%+v", byRules, split)
+			}
+		})
+	}
+}
+
+// countingProcessor matches every element and is done after the given number of them.
+type countingProcessor struct {
+	seen, doneAfter int
+}
+
+func (c *countingProcessor) Predicate(n *html.Node) bool { return n.Type == html.ElementNode }
+func (c *countingProcessor) ProcessNode(n *html.Node)    { c.seen++ }
+func (c *countingProcessor) Done() bool                  { return c.doneAfter > 0 && c.seen >= c.doneAfter }
+
+func TestTraversalSkipsProcessorsWhenDone(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(strings.Repeat("<div><p>a</p></div>", 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	early := &countingProcessor{doneAfter: 3}
+	all := &countingProcessor{}
+	traverseHtmlTreeAndExecuteExtractors(doc, early, all)
+	if early.seen != 3 {
+		t.Errorf("expected the processor to get 3 nodes until done, got %d", early.seen)
+	}
+	if all.seen != 23 { // html, head, body and 10 div > p
+		t.Errorf("expected the other processor to get every element, got %d", all.seen)
+	}
+}
+
+func BenchmarkDetailPageTraversal(b *testing.B) {
+	traversals := []struct {
+		name     string
+		traverse func(*html.Node, ...HtmlNodeProcessor)
+	}{
+		{"per_extractor", traverseOncePerExtractor},
+		{"single_pass", traverseHtmlTreeAndExecuteExtractors},
+	}
+
+	// real detail pages are a few thousand elements, the saved ones a few dozen
+	for _, padding := range []int{0, 400} {
+		for _, page := range savedDetailPages(b, padding) {
+			for _, tr := range traversals {
+				page, tr := page, tr
+				name := page.name + "/" + tr.name
+				if padding != 0 {
+					name = page.name + "_large/" + tr.name
+				}
+				b.Run(name, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						extractProperty(page.doc, page.portal.NewPageDataExtractors(), tr.traverse)
+					}
+				})
+			}
+		}
+	}
+}
@@ -1,33 +1,187 @@
 package crawlers
 
 import (
+	"context"
+	"errors"
 	"log"
+	"math/rand"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-func sendGetRequest(url string) (*http.Response, error) {
-	return sendRequest(url, "GET")
+const (
+	defaultTimeoutSeconds = 5
+	defaultMaxRetries     = 3
+	defaultUserAgent      = "Mozilla/5.0 (X11; Fedora; Linux x86_64; rv:90.0) Gecko/20100101 Firefox/90.0"
+
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = time.Minute
+)
+
+// FetcherConfig holds the settings of the http client used for crawling.
+// Zero values fall back to the defaults, a negative MaxRetries disables retrying.
+type FetcherConfig struct {
+	TimeoutSeconds int    `json:"időkorlát_mp"`
+	MaxRetries     int    `json:"max_újrapróbálkozás"`
+	UserAgent      string `json:"user_agent"`
+
+	// FixtureMode is FixtureRecord or FixtureReplay to save the responses
+	// into FixturesDir or to serve them from there instead of the network.
+	FixtureMode string `json:"felvétel_mód"`
+	FixturesDir string `json:"felvételek_mappa"`
 }
 
-func sendRequest(url, method string) (*http.Response, error) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+// Fetcher sends requests through one shared, pooled http client and retries
+// transient failures with exponential backoff.
+type Fetcher struct {
+	client     *http.Client
+	userAgent  string
+	maxRetries int
+}
+
+// DefaultFetcher is used by the collector functions for every request.
+var DefaultFetcher = NewFetcher(FetcherConfig{})
+
+func NewFetcher(c FetcherConfig) *Fetcher {
+	timeout := c.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultTimeoutSeconds
+	}
+	maxRetries := c.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	} else if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	userAgent := c.UserAgent
+	if len(userAgent) == 0 {
+		userAgent = defaultUserAgent
+	}
+
+	pooled := http.DefaultTransport.(*http.Transport).Clone()
+	pooled.MaxIdleConns = 100
+	pooled.MaxIdleConnsPerHost = 16
+
+	var transport http.RoundTripper = pooled
+	switch c.FixtureMode {
+	case FixtureRecord:
+		transport = recordingTransport{next: pooled, dir: c.FixturesDir}
+	case FixtureReplay:
+		transport = replayTransport{dir: c.FixturesDir}
+		maxRetries = 0 // a missing fixture will not show up by retrying
 	}
 
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		log.Printf("error when creating request for '%s' with method '%s'\n", url, method)
-		return nil, err
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   time.Duration(timeout) * time.Second,
+			Transport: transport,
+		},
+		userAgent:  userAgent,
+		maxRetries: maxRetries,
 	}
+}
+
+func (f *Fetcher) Get(ctx context.Context, url string) (*http.Response, error) {
+	return f.Do(ctx, url, "GET")
+}
+
+// Do sends the request, retrying network errors, 429 and 5xx responses.
+// When the retries run out the last response (or error) is returned as is.
+func (f *Fetcher) Do(ctx context.Context, url, method string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			log.Printf("error when creating request for '%s' with method '%s'\n", url, method)
+			return nil, err
+		}
+		req.Header.Set("User-Agent", f.userAgent)
+
+		start := time.Now()
+		resp, err := f.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= f.maxRetries {
+				log.Printf("error when querying url %s with method %s: %s\n", url, method, err)
+				return nil, err
+			}
+			wait := backoffDuration(attempt)
+			log.Printf("error when querying url %s, retrying in %s: %s\n", url, wait, err)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		verbosef("%s %s: %d in %s\n", method, url, resp.StatusCode, time.Since(start).Round(time.Millisecond))
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Fedora; Linux x86_64; rv:90.0) Gecko/20100101 Firefox/90.0")
+		if !isRetryableStatus(resp.StatusCode) || attempt >= f.maxRetries {
+			return resp, nil
+		}
 
-	resp, err := client.Do(req)
+		wait := backoffDuration(attempt)
+		if retryAfter, err := parseRetryAfter(resp.Header.Get("Retry-After")); err == nil {
+			if retryAfter > maxBackoff {
+				// the server wants us to wait longer than we are willing to, give up
+				return resp, nil
+			}
+			wait = retryAfter
+		}
+		resp.Body.Close()
+
+		log.Printf("got status %d from %s, retrying in %s\n", resp.StatusCode, url, wait)
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepContext waits for the given duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// backoffDuration returns the exponential backoff for the given attempt with jitter.
+func backoffDuration(attempt int) time.Duration {
+	d := baseBackoff << uint(attempt)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter understands both forms of the header: delay in seconds and http date.
+func parseRetryAfter(v string) (time.Duration, error) {
+	if len(v) == 0 {
+		return 0, errors.New("no Retry-After header")
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			seconds = 0
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	t, err := http.ParseTime(v)
 	if err != nil {
-		log.Printf("error when querying url %s with method %s: %/s\n", url, method, err)
-		return nil, err
+		return 0, err
 	}
+	wait := time.Until(t)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, nil
+}
 
-	return resp, nil
+func sendGetRequest(ctx context.Context, url string) (*http.Response, error) {
+	return DefaultFetcher.Get(ctx, url)
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -12,6 +13,95 @@ import (
 
 var IngatlanBaseUrl string = "https://ingatlan.com/"
 
+func init() {
+	RegisterPortal(IngatlanComPortal{})
+}
+
+type IngatlanComPortal struct{}
+
+func (IngatlanComPortal) Name() string {
+	return "ingatlan.com"
+}
+
+func (IngatlanComPortal) BaseUrl() string {
+	return IngatlanBaseUrl
+}
+
+func (IngatlanComPortal) SetBaseUrl(baseUrl string) {
+	IngatlanBaseUrl = baseUrl
+}
+
+func (IngatlanComPortal) QueryUrl(s Search) string {
+	return CrateIngatlanQueryUrl(s)
+}
+
+var ingatlanListingIdRegexp = regexp.MustCompile(`^(\d+)$`)
+
+// ListingId returns the numeric id ending the listing urls, e.g. https://ingatlan.com/32145678
+func (IngatlanComPortal) ListingId(link string) (string, error) {
+	return lastPathSegmentMatching(link, ingatlanListingIdRegexp)
+}
+
+var ingatlanComValueMappings = ValueMappings{
+	Conditions: map[string]ConditionCategory{
+		"új építésű":   ConditionNew,
+		"újszerű":      ConditionAsNew,
+		"felújított":   ConditionRenovated,
+		"jó":           ConditionGood,
+		"közepes":      ConditionAverage,
+		"felújítandó":  ConditionNeedsRenovation,
+		"befejezetlen": ConditionUnderConstruction,
+	},
+	Heatings: map[string]HeatingType{
+		"gáz (cirko)":           HeatingGasBoiler,
+		"gáz (héra)":            HeatingGasConvector,
+		"gáz (konvektor)":       HeatingGasConvector,
+		"távfűtés":              HeatingDistrict,
+		"házközponti":           HeatingCentral,
+		"elektromos":            HeatingElectric,
+		"geotermikus":           HeatingHeatPump,
+		"hőszivattyú":           HeatingHeatPump,
+		"vegyes tüzelésű kazán": HeatingSolidFuel,
+		"cserépkályha":          HeatingSolidFuel,
+		"padlófűtés":            HeatingUnderfloor,
+		"fan-coil":              HeatingOther,
+		"egyéb":                 HeatingOther,
+	},
+	Parkings: map[string]ParkingType{
+		"önálló garázs": ParkingGarage,
+		"garázs":        ParkingGarage,
+		"teremgarázs":   ParkingUnderground,
+		"udvari beálló": ParkingCourtyard,
+		"utca":          ParkingStreet,
+		"közterület":    ParkingStreet,
+		"nincs":         ParkingNone,
+		// must be listed, otherwise it would match "nincs" as a prefix
+		"nincs megadva": ParkingUnknown,
+	},
+	AirConditioning: map[string]AirConditioningType{
+		"van":   AirConditioningYes,
+		"nincs": AirConditioningNo,
+		// must be listed, otherwise it would match "nincs" as a prefix
+		"nincs megadva": AirConditioningUnknown,
+	},
+}
+
+func (IngatlanComPortal) ValueMappings() ValueMappings {
+	return ingatlanComValueMappings
+}
+
+func (IngatlanComPortal) NewListingPagesExtractor() ListingPagesExtractor {
+	return &IngatlanComListingPagesExtractor{}
+}
+
+func (IngatlanComPortal) NewLinkCollector() LinkExtractor {
+	return &IngatlanComLinkCollector{}
+}
+
+func (IngatlanComPortal) NewPageDataExtractors() []PageDataExtractor {
+	return []PageDataExtractor{&IngatlanComMainInfoExtractor{}, &IngatlanComPropertyInfoExtractor{}, &IngatlanComAddressExtractor{}, &GeoLocationExtractor{}}
+}
+
 type IngatlanComLinkCollector struct {
 	Links []string
 }
@@ -43,8 +133,8 @@ func (lpe *IngatlanComListingPagesExtractor) Predicate(n *html.Node) bool {
 }
 
 func (lpe *IngatlanComListingPagesExtractor) ProcessNode(n *html.Node) {
-	pageDesc := n.FirstChild.Data
-	if pageDesc == "" {
+	pageDesc := nodeText(n)
+	if !strings.Contains(pageDesc, "/") {
 		return
 	}
 
@@ -59,6 +149,11 @@ func (lpe *IngatlanComListingPagesExtractor) ProcessNode(n *html.Node) {
 	lpe.maxPageNumber = maxNum
 }
 
+// Done reports whether the page count was found, the pagination is repeated at the bottom.
+func (lpe *IngatlanComListingPagesExtractor) Done() bool {
+	return lpe.maxPageNumber > 0
+}
+
 func (lpe *IngatlanComListingPagesExtractor) MaxPageNumber() int {
 	return lpe.maxPageNumber
 }
@@ -69,6 +164,7 @@ func (lpe *IngatlanComListingPagesExtractor) NextPageFormat() string {
 
 type IngatlanComPropertyInfoExtractor struct {
 	Condition, BuiltIn, NumOfFloors, Parking, Heating, AirConditioning, ToiletAndBathroom string
+	Deposit, UtilitiesIncluded, MinLeaseTerm                                              string
 }
 
 func (p *IngatlanComPropertyInfoExtractor) Predicate(n *html.Node) bool {
@@ -97,6 +193,12 @@ func (p *IngatlanComPropertyInfoExtractor) ProcessNode(n *html.Node) {
 			p.AirConditioning = paramVal
 		case "Fürdő és WC":
 			p.ToiletAndBathroom = paramVal
+		case "Kaució":
+			p.Deposit = paramVal
+		case "Rezsiköltség", "Rezsi":
+			p.UtilitiesIncluded = paramVal
+		case "Min. bérleti idő", "Minimális bérleti idő":
+			p.MinLeaseTerm = paramVal
 		}
 	}
 }
@@ -109,11 +211,14 @@ func (e *IngatlanComPropertyInfoExtractor) AddInfoIntoProp(p *PropertyInfo) {
 	p.Heating = e.Heating
 	p.AirConditioning = e.AirConditioning
 	p.ToiletAndBathroom = e.ToiletAndBathroom
+	p.Deposit = depositInHuf(e.Deposit, p.MonthlyRent) // the main info extractor ran before
+	p.UtilitiesIncluded = e.UtilitiesIncluded
+	p.MinLeaseTerm = e.MinLeaseTerm
 }
 
 type IngatlanComMainInfoExtractor struct {
-	HouseArea, LotArea, NumOfRooms int
-	Price, PricePerSqrMeter        float64
+	HouseArea, LotArea, NumOfRooms       int
+	Price, MonthlyRent, PricePerSqrMeter float64
 }
 
 func (m *IngatlanComMainInfoExtractor) Predicate(n *html.Node) bool {
@@ -131,30 +236,47 @@ func (m *IngatlanComMainInfoExtractor) Predicate(n *html.Node) bool {
 
 func (m *IngatlanComMainInfoExtractor) ProcessNode(n *html.Node) {
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		fc := c.FirstChild
+		if c.Type != html.ElementNode {
+			continue
+		}
+		fc := firstElementChild(c)
+		if fc == nil {
+			continue
+		}
 
 		if isPriceHeaderNode(fc) {
 			priceNode := findParameterValuesClassAmongSiblings(fc)
 
-			price, err := extractPriceFromNode(priceNode)
+			huf, monthly, err := extractPriceFromNode(priceNode)
 			if err != nil {
 				log.Println(err)
 				continue
 			}
 
-			m.Price = price
+			setListedPrice(huf, monthly, &m.Price, &m.MonthlyRent)
 		}
 		if isNodeParameterTitle(fc) {
-			if fc.FirstChild == nil {
-				break
+			paramName := nodeText(fc)
+			if paramName == "" {
+				continue
 			}
-			paramName := strings.TrimSpace(fc.FirstChild.Data)
 			valueNode := findParameterValuesClassAmongSiblings(fc)
 			if valueNode == nil {
 				log.Printf("did not found value node for '%s'", paramName)
 				break
 			}
 
+			// rentals have no loan calculator link, the rent is a parameter of its own
+			if paramName == "Ár havonta" || paramName == "Bérleti díj" {
+				huf, _, err := extractPriceFromNode(valueNode)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				m.MonthlyRent = huf
+				continue
+			}
+
 			val, err := extractIntValueFromNode(valueNode)
 			if err != nil {
 				log.Printf("could not extract value from node: %s\n", err)
@@ -172,7 +294,13 @@ func (m *IngatlanComMainInfoExtractor) ProcessNode(n *html.Node) {
 		}
 	}
 
-	m.PricePerSqrMeter = (m.Price / float64(m.HouseArea)) * 1000000.0 // converting it to millionHUF -> HUF
+	if m.HouseArea > 0 { // plots have no area to divide with
+		if m.MonthlyRent > 0 {
+			m.PricePerSqrMeter = m.MonthlyRent / float64(m.HouseArea)
+		} else {
+			m.PricePerSqrMeter = (m.Price / float64(m.HouseArea)) * 1000000.0 // converting it to millionHUF -> HUF
+		}
+	}
 }
 
 func (m *IngatlanComMainInfoExtractor) AddInfoIntoProp(p *PropertyInfo) {
@@ -180,6 +308,7 @@ func (m *IngatlanComMainInfoExtractor) AddInfoIntoProp(p *PropertyInfo) {
 	p.LotArea = m.LotArea
 	p.NumOfRooms = m.NumOfRooms
 	p.Price = m.Price
+	p.MonthlyRent = m.MonthlyRent
 	p.PricePerSqrMeter = m.PricePerSqrMeter
 }
 
@@ -207,30 +336,49 @@ func (a *IngatlanComAddressExtractor) ProcessNode(n *html.Node) {
 	a.Address = strings.TrimSpace(textNode.Data)
 }
 
+func (a *IngatlanComAddressExtractor) Done() bool {
+	return len(a.Address) != 0
+}
+
 func (a *IngatlanComAddressExtractor) AddInfoIntoProp(p *PropertyInfo) {
 	p.Address = a.Address
 }
 
-func CrateIngatlanQueryUrl(c Config) string {
-	url := JoinUri(IngatlanBaseUrl, "lista/elado")
+func CrateIngatlanQueryUrl(c Search) string {
+	url := JoinUri(IngatlanBaseUrl, "lista/"+string(c.Transaction.OrDefault()))
 	// lakas/haz
-	url += fmt.Sprintf("+%s+%d-%d-m2+%d-%d-mFt", c.Type, c.MinSize, c.MaxSize, c.MinPrice, c.MaxPrice)
-	for _, dist := range c.Districts {
-		url += fmt.Sprintf("+%s-ker", dist)
+	url += fmt.Sprintf("+%s+%d-%d-m2", c.Type, c.MinSize, c.MaxSize)
+	if c.Transaction.OrDefault() == Rent {
+		url += fmt.Sprintf("+%d-%d-ezer-Ft", c.MinPrice, c.MaxPrice)
+	} else {
+		url += fmt.Sprintf("+%d-%d-mFt", c.MinPrice, c.MaxPrice)
+	}
+	locations, _ := c.ResolveLocations() // validated before crawling
+	for _, l := range locations {
+		url += "+" + ingatlanLocationSlug(l)
 	}
 	return url
 }
 
+// ingatlanLocationSlug returns e.g. "xi-ker", "budaors" or "pest-megye".
+func ingatlanLocationSlug(l Location) string {
+	if l.Kind == LocationDistrict {
+		return l.Roman() + "-ker"
+	}
+	return l.Slug()
+}
+
 func extractIntValueFromNode(n *html.Node) (int, error) {
-	if n.FirstChild == nil {
+	span := firstElementChild(n)
+	if span == nil {
 		return 0, errors.New("unknown format, expected node does not exists")
 	}
 
-	if n.FirstChild.Data != "span" {
-		return 0, fmt.Errorf("unknown format, expected 'span' node, got %s", n.FirstChild.Data)
+	if span.Data != "span" {
+		return 0, fmt.Errorf("unknown format, expected 'span' node, got %s", span.Data)
 	}
 
-	valAsString := n.FirstChild.FirstChild.Data
+	valAsString := nodeText(span)
 
 	val, err := extractInValueFromString(valAsString)
 	if err != nil {
@@ -253,35 +401,18 @@ func extractInValueFromString(s string) (int, error) {
 	return val, nil
 }
 
-func extractPriceFromNode(priceNode *html.Node) (float64, error) {
+// extractPriceFromNode returns the price in HUF and whether it is a monthly rent.
+func extractPriceFromNode(priceNode *html.Node) (float64, bool, error) {
 	if priceNode == nil {
-		return 0.0, errors.New("node containing the price not found")
+		return 0.0, false, errors.New("node containing the price not found")
 	}
-	if priceNode.FirstChild == nil || priceNode.FirstChild.Data != "span" {
-		return 0.0, errors.New("node containing the price not found")
+	span := firstElementChild(priceNode)
+	if span == nil || span.Data != "span" {
+		return 0.0, false, errors.New("node containing the price not found")
 	}
 
 	// div > span > text
-	priceAsString := priceNode.FirstChild.FirstChild.Data
-
-	price, err := extractPriceFromString(priceAsString)
-	if err != nil {
-		return 0.0, err
-	}
-
-	return price, nil
-}
-
-func extractPriceFromString(s string) (float64, error) {
-	priceAsString := strings.Split(s, " ")[0]
-	priceAsString = strings.Replace(priceAsString, ",", ".", 1)
-
-	price, err := strconv.ParseFloat(priceAsString, 64)
-	if err != nil {
-		return 0.0, errors.New("could not convert %s to float")
-	}
-
-	return price, nil
+	return parsePriceText(nodeText(span))
 }
 
 func isNodeListingLink(n *html.Node) bool {
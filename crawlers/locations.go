@@ -0,0 +1,102 @@
+package crawlers
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type LocationKind string
+
+const (
+	LocationDistrict LocationKind = "kerület" // a district of Budapest
+	LocationCity     LocationKind = "város"
+	LocationCounty   LocationKind = "megye"
+)
+
+// Location is a place a search can be restricted to. Portals build their
+// own url slugs from it.
+type Location struct {
+	Kind     LocationKind
+	Name     string
+	District int // 1-23 for Budapest districts, 0 otherwise
+}
+
+// Slug is the lower case ascii form of the name, e.g. "budaors" or "pest-megye".
+func (l Location) Slug() string {
+	return slugify(l.Name)
+}
+
+// Roman returns the district number as a lower case roman numeral, e.g. "xi".
+func (l Location) Roman() string {
+	if l.District < 1 || l.District > len(romanDistricts) {
+		return ""
+	}
+	return romanDistricts[l.District-1]
+}
+
+func (l Location) String() string {
+	return l.Name
+}
+
+var romanDistricts = []string{
+	"i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix", "x", "xi", "xii",
+	"xiii", "xiv", "xv", "xvi", "xvii", "xviii", "xix", "xx", "xxi", "xxii", "xxiii",
+}
+
+// locationsCsv lists the cities and counties that can be searched, Budapest
+// districts are not listed as they are recognized by their number.
+//
+//go:embed locations.csv
+var locationsCsv string
+
+// knownLocations are the bundled cities and counties keyed by their slug.
+var knownLocations = readKnownLocations()
+
+func readKnownLocations() map[string]Location {
+	records, err := csv.NewReader(strings.NewReader(locationsCsv)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("bundled location list is invalid: %s", err))
+	}
+
+	locations := make(map[string]Location, len(records))
+	for _, r := range records[1:] {
+		l := Location{Kind: LocationKind(r[0]), Name: r[1]}
+		locations[l.Slug()] = l
+	}
+	return locations
+}
+
+var districtRegexp = regexp.MustCompile(`^([ivx]+)\.?\s*(ker|kerület|kerulet)?\.?$`)
+
+// ParseLocation resolves a location given in the config. Districts are
+// given by their roman numeral ("xi", "XI. kerület"), cities and counties by
+// their name, with or without accents ("Budaörs", "pest-megye").
+func ParseLocation(s string) (Location, error) {
+	lower := strings.ToLower(strings.TrimSpace(s))
+
+	if m := districtRegexp.FindStringSubmatch(lower); m != nil {
+		for i, r := range romanDistricts {
+			if r == m[1] {
+				return Location{Kind: LocationDistrict, Name: fmt.Sprintf("%s. kerület", strings.ToUpper(r)), District: i + 1}, nil
+			}
+		}
+		return Location{}, fmt.Errorf("unknown Budapest district '%s'", s)
+	}
+
+	if l, ok := knownLocations[slugify(lower)]; ok {
+		return l, nil
+	}
+	return Location{}, fmt.Errorf("unknown location '%s'", s)
+}
+
+// slugify turns a Hungarian name into the form the portals use in urls.
+func slugify(name string) string {
+	name = accentReplacer.Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,282 @@
+package crawlers
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultAreaTolerancePercent  = 3.0
+	defaultPriceTolerancePercent = 5.0
+	defaultMinMatchScore         = 0.7
+)
+
+// MatchConfig tunes how listings of different portals are recognised as the same property.
+// Zero values fall back to the defaults.
+type MatchConfig struct {
+	AreaTolerancePercent  float64 `json:"terület_tűrés_százalék"`
+	PriceTolerancePercent float64 `json:"ár_tűrés_százalék"`
+	MinScore              float64 `json:"min_pontszám"`
+}
+
+func (mc MatchConfig) withDefaults() MatchConfig {
+	if mc.AreaTolerancePercent <= 0 {
+		mc.AreaTolerancePercent = defaultAreaTolerancePercent
+	}
+	if mc.PriceTolerancePercent <= 0 {
+		mc.PriceTolerancePercent = defaultPriceTolerancePercent
+	}
+	if mc.MinScore <= 0 {
+		mc.MinScore = defaultMinMatchScore
+	}
+	return mc
+}
+
+// PropertyGroup is a single real property with every listing found for it.
+type PropertyGroup struct {
+	Canonical      PropertyInfo
+	Sources        []PropertyInfo // cheapest first
+	CheapestPortal string
+}
+
+// GroupDuplicates groups the listings of different portals advertising the same property.
+// Only listings with similar house area are compared, so the cost stays close to linear.
+func GroupDuplicates(props []PropertyInfo, mc MatchConfig) []PropertyGroup {
+	mc = mc.withDefaults()
+
+	// Buckets grow with the area by the tolerance, so a match can only be in
+	// the same or a neighbouring bucket. The tolerance is measured against the
+	// larger area, min >= max*(1-t), hence the width of -log(1-t). Listings
+	// without area never match.
+	width := math.Inf(1) // everything is within a tolerance of 100%
+	if mc.AreaTolerancePercent < 100 {
+		width = -math.Log1p(-mc.AreaTolerancePercent / 100)
+	}
+	bucketOf := func(p PropertyInfo) int {
+		return int(math.Floor(math.Log(float64(p.HouseArea)) / width))
+	}
+	buckets := make(map[int][]int)
+	for i, p := range props {
+		if p.HouseArea > 0 {
+			buckets[bucketOf(p)] = append(buckets[bucketOf(p)], i)
+		}
+	}
+
+	parent := make([]int, len(props))
+	portals := make([]map[string]bool, len(props)) // portals of the group, by root
+	for i, p := range props {
+		parent[i] = i
+		portals[i] = map[string]bool{p.Portal: true}
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	// union refuses to merge groups having listings of the same portal, a
+	// portal does not advertise a property twice. Without that similar
+	// listings would be chained into one group through a third portal.
+	union := func(ri, rj int) {
+		if ri == rj {
+			return
+		}
+		for portal := range portals[rj] {
+			if portals[ri][portal] {
+				return
+			}
+		}
+		for portal := range portals[rj] {
+			portals[ri][portal] = true
+		}
+		parent[rj] = ri
+	}
+
+	for i, p := range props {
+		if p.HouseArea <= 0 {
+			continue
+		}
+		b := bucketOf(p)
+		for _, nb := range []int{b - 1, b, b + 1} {
+			for _, j := range buckets[nb] {
+				if j <= i || props[j].Portal == p.Portal {
+					continue
+				}
+				if MatchScore(p, props[j], mc) >= mc.MinScore {
+					union(find(i), find(j))
+				}
+			}
+		}
+	}
+
+	var roots []int
+	members := make(map[int][]PropertyInfo)
+	for i, p := range props {
+		r := find(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], p)
+	}
+
+	groups := make([]PropertyGroup, 0, len(roots))
+	for _, r := range roots {
+		groups = append(groups, newPropertyGroup(members[r]))
+	}
+	return groups
+}
+
+func newPropertyGroup(sources []PropertyInfo) PropertyGroup {
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].ListedPrice() < sources[j].ListedPrice()
+	})
+
+	canonical := sources[0]
+	for _, s := range sources[1:] {
+		fillMissingFields(&canonical, s)
+	}
+
+	return PropertyGroup{Canonical: canonical, Sources: sources, CheapestPortal: sources[0].Portal}
+}
+
+// fillMissingFields copies the fields of src that are empty in dst.
+func fillMissingFields(dst *PropertyInfo, src PropertyInfo) {
+	fill := func(dst *string, src string) {
+		if len(*dst) == 0 {
+			*dst = src
+		}
+	}
+	fill(&dst.Address, src.Address)
+	fill(&dst.Condition, src.Condition)
+	fill(&dst.Parking, src.Parking)
+	fill(&dst.BuiltIn, src.BuiltIn)
+	fill(&dst.NumOfFloors, src.NumOfFloors)
+	fill(&dst.Heating, src.Heating)
+	fill(&dst.AirConditioning, src.AirConditioning)
+	fill(&dst.ToiletAndBathroom, src.ToiletAndBathroom)
+	if dst.LotArea == 0 {
+		dst.LotArea = src.LotArea
+	}
+	if dst.NumOfRooms == 0 {
+		dst.NumOfRooms = src.NumOfRooms
+	}
+	if dst.ConditionCategory == ConditionUnknown {
+		dst.ConditionCategory = src.ConditionCategory
+	}
+	if dst.HeatingType == HeatingUnknown {
+		dst.HeatingType = src.HeatingType
+	}
+	if dst.ParkingType == ParkingUnknown {
+		dst.ParkingType = src.ParkingType
+	}
+	if dst.AirConditioningType == AirConditioningUnknown {
+		dst.AirConditioningType = src.AirConditioningType
+	}
+	if dst.BuildYearFrom == 0 && dst.BuildYearTo == 0 {
+		dst.BuildYearFrom, dst.BuildYearTo = src.BuildYearFrom, src.BuildYearTo
+	}
+	if dst.Floors == 0 {
+		dst.Floors = src.Floors
+	}
+	if dst.Deposit == 0 {
+		dst.Deposit = src.Deposit
+	}
+	fill(&dst.UtilitiesIncluded, src.UtilitiesIncluded)
+	fill(&dst.MinLeaseTerm, src.MinLeaseTerm)
+	if dst.Latitude == 0 && dst.Longitude == 0 {
+		dst.Latitude, dst.Longitude = src.Latitude, src.Longitude
+	}
+}
+
+// MatchScore returns how likely it is that the two listings advertise the same
+// property, between 0 and 1. House area and price outside the tolerance rule out a match,
+// the other attributes are only compared when both listings have them.
+func MatchScore(a, b PropertyInfo, mc MatchConfig) float64 {
+	mc = mc.withDefaults()
+
+	if a.IsRental() != b.IsRental() ||
+		!withinPercent(float64(a.HouseArea), float64(b.HouseArea), mc.AreaTolerancePercent) ||
+		!withinPercent(a.ListedPrice(), b.ListedPrice(), mc.PriceTolerancePercent) {
+		return 0
+	}
+
+	var score, weight float64
+	add := func(w, s float64) {
+		score += w * s
+		weight += w
+	}
+
+	add(2, 1) // house area and price
+	if a.LotArea != 0 && b.LotArea != 0 {
+		add(1, boolScore(withinPercent(float64(a.LotArea), float64(b.LotArea), mc.AreaTolerancePercent)))
+	}
+	if a.NumOfRooms != 0 && b.NumOfRooms != 0 {
+		add(1, boolScore(a.NumOfRooms == b.NumOfRooms))
+	}
+	if a.BuildYearFrom+a.BuildYearTo != 0 && b.BuildYearFrom+b.BuildYearTo != 0 {
+		add(1, boolScore(a.BuildYearFrom == b.BuildYearFrom && a.BuildYearTo == b.BuildYearTo))
+	}
+	ta, tb := addressTokens(a.Address), addressTokens(b.Address)
+	if len(ta) != 0 && len(tb) != 0 {
+		add(2, jaccard(ta, tb))
+	}
+
+	return score / weight
+}
+
+func withinPercent(a, b, percent float64) bool {
+	if a <= 0 || b <= 0 {
+		return false
+	}
+	return math.Abs(a-b)/math.Max(a, b)*100 <= percent
+}
+
+func boolScore(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ö", "o", "ő", "o", "ú", "u", "ü", "u", "ű", "u",
+)
+
+var addressAbbreviations = map[string]string{
+	"utca": "u", "ut": "u", "krt": "korut", "ker": "kerulet", "bp": "budapest",
+}
+
+// addressTokens normalizes an address into a set of comparable words.
+func addressTokens(address string) map[string]bool {
+	address = accentReplacer.Replace(strings.ToLower(address))
+	words := strings.FieldsFunc(address, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+
+	tokens := make(map[string]bool)
+	for _, w := range words {
+		if abbr, ok := addressAbbreviations[w]; ok {
+			w = abbr
+		}
+		tokens[w] = true
+	}
+	return tokens
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	common := 0
+	for t := range a {
+		if b[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// WritePropertyGroupsAsCsv writes every property once, followed by the links of all its listings.
+func WritePropertyGroupsAsCsv(w io.Writer, groups []PropertyGroup) error {
+	return writeCsv(w, CsvConfig{}, Columns, groups)
+}
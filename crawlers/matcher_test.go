@@ -0,0 +1,54 @@
+package crawlers
+
+import "testing"
+
+func TestGroupDuplicatesAtTheAreaTolerance(t *testing.T) {
+	tests := []struct {
+		name      string
+		tolerance float64
+		areas     [2]int
+		groups    int
+	}{
+		// the areas differ by exactly the tolerance of the larger one
+		{"default tolerance", 0, [2]int{2522, 2600}, 1},
+		{"5 percent", 5, [2]int{152, 160}, 1},
+		{"just outside", 5, [2]int{151, 160}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := []PropertyInfo{
+				{Portal: "ingatlan.com", ListingId: "1", Address: "Budapest XI. kerület, Sasadi út 12.", Price: 120, HouseArea: tt.areas[0], NumOfRooms: 5},
+				{Portal: "dunahouse", ListingId: "H1", Address: "Budapest XI. kerület, Sasadi út 12.", Price: 120, HouseArea: tt.areas[1], NumOfRooms: 5},
+			}
+
+			groups := GroupDuplicates(props, MatchConfig{AreaTolerancePercent: tt.tolerance})
+			if len(groups) != tt.groups {
+				t.Errorf("expected %d group(s) of %d and %d m2, got %d", tt.groups, tt.areas[0], tt.areas[1], len(groups))
+			}
+		})
+	}
+}
+
+func TestGroupDuplicatesKeepsListingsOfAPortalApart(t *testing.T) {
+	address := "Budapest XI. kerület, Sasadi út 12."
+	props := []PropertyInfo{
+		{Portal: "ingatlan.com", ListingId: "1", Address: address, Price: 50, HouseArea: 60, NumOfRooms: 2},
+		{Portal: "dunahouse", ListingId: "H1", Address: address, Price: 51, HouseArea: 61, NumOfRooms: 2},
+		{Portal: "ingatlan.com", ListingId: "2", Address: address, Price: 52, HouseArea: 62, NumOfRooms: 2},
+	}
+
+	groups := GroupDuplicates(props, MatchConfig{})
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	for _, g := range groups {
+		seen := map[string]bool{}
+		for _, s := range g.Sources {
+			if seen[s.Portal] {
+				t.Errorf("expected at most one listing of %s in a group, got %+v", s.Portal, g.Sources)
+			}
+			seen[s.Portal] = true
+		}
+	}
+}
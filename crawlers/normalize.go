@@ -0,0 +1,155 @@
+package crawlers
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The normalized values are portal independent, the raw text each portal
+// shows stays in the string fields of PropertyInfo.
+
+type ConditionCategory string
+
+const (
+	ConditionUnknown           ConditionCategory = ""
+	ConditionNew               ConditionCategory = "new"
+	ConditionAsNew             ConditionCategory = "as_new"
+	ConditionRenovated         ConditionCategory = "renovated"
+	ConditionGood              ConditionCategory = "good"
+	ConditionAverage           ConditionCategory = "average"
+	ConditionNeedsRenovation   ConditionCategory = "needs_renovation"
+	ConditionUnderConstruction ConditionCategory = "under_construction"
+)
+
+type HeatingType string
+
+const (
+	HeatingUnknown      HeatingType = ""
+	HeatingGasBoiler    HeatingType = "gas_boiler"
+	HeatingGasConvector HeatingType = "gas_convector"
+	HeatingDistrict     HeatingType = "district"
+	HeatingCentral      HeatingType = "central"
+	HeatingElectric     HeatingType = "electric"
+	HeatingHeatPump     HeatingType = "heat_pump"
+	HeatingSolidFuel    HeatingType = "solid_fuel"
+	HeatingUnderfloor   HeatingType = "underfloor"
+	HeatingOther        HeatingType = "other"
+)
+
+type ParkingType string
+
+const (
+	ParkingUnknown     ParkingType = ""
+	ParkingGarage      ParkingType = "garage"
+	ParkingUnderground ParkingType = "underground"
+	ParkingCourtyard   ParkingType = "courtyard"
+	ParkingStreet      ParkingType = "street"
+	ParkingNone        ParkingType = "none"
+)
+
+type AirConditioningType string
+
+const (
+	AirConditioningUnknown AirConditioningType = ""
+	AirConditioningYes     AirConditioningType = "yes"
+	AirConditioningNo      AirConditioningType = "no"
+)
+
+// ValueMappings translate the raw texts of a portal to the normalized values.
+// Keys are matched case insensitively after trimming, then as a prefix of the
+// raw text, so "felújított" also maps "felújított állapotú".
+type ValueMappings struct {
+	Conditions      map[string]ConditionCategory
+	Heatings        map[string]HeatingType
+	Parkings        map[string]ParkingType
+	AirConditioning map[string]AirConditioningType
+}
+
+// NormalizeProperty fills the normalized fields of p from its raw strings.
+func NormalizeProperty(p *PropertyInfo, m ValueMappings) {
+	p.ConditionCategory = m.Conditions[mappingKey(p.Condition, m.Conditions)]
+	p.HeatingType = m.Heatings[mappingKey(firstListItem(p.Heating), m.Heatings)]
+	p.ParkingType = m.Parkings[mappingKey(firstListItem(p.Parking), m.Parkings)]
+	p.AirConditioningType = m.AirConditioning[mappingKey(p.AirConditioning, m.AirConditioning)]
+	p.BuildYearFrom, p.BuildYearTo = ParseBuildYear(p.BuiltIn)
+	p.Floors = ParseFloors(p.NumOfFloors)
+}
+
+// mappingKey returns the key of the mapping table (a map with string keys)
+// matching the raw text, or "" if none does.
+func mappingKey(raw string, table interface{}) string {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if len(raw) == 0 {
+		return ""
+	}
+
+	// prefer the longest matching prefix, so "új építésű" beats "új"
+	best := ""
+	for _, k := range reflect.ValueOf(table).MapKeys() {
+		key := k.String()
+		lower := strings.ToLower(key)
+		if lower == raw {
+			return key
+		}
+		if strings.HasPrefix(raw, lower) && len(key) > len(best) {
+			best = key
+		}
+	}
+	return best
+}
+
+// firstListItem returns the first item of a comma separated list, portals
+// list e.g. every heating type of the house this way.
+func firstListItem(s string) string {
+	return strings.TrimSpace(strings.Split(s, ",")[0])
+}
+
+var (
+	yearRangeRegexp  = regexp.MustCompile(`(\d{4})\D+(\d{4})`)
+	yearBeforeRegexp = regexp.MustCompile(`(\d{4})\s*(előtt|elött)`)
+	yearAfterRegexp  = regexp.MustCompile(`(\d{4})\s*(után|utan)`)
+	singleYearRegexp = regexp.MustCompile(`\d{4}`)
+)
+
+// ParseBuildYear understands a single year ("2004"), ranges ("1981 és 2000
+// között") and open ranges ("1950 előtt", "2010 után"). Unknown ends are 0.
+func ParseBuildYear(s string) (from, to int) {
+	s = strings.ToLower(s)
+
+	if m := yearRangeRegexp.FindStringSubmatch(s); m != nil {
+		from, _ = strconv.Atoi(m[1])
+		to, _ = strconv.Atoi(m[2])
+		return from, to
+	}
+	if m := yearBeforeRegexp.FindStringSubmatch(s); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		return 0, year - 1
+	}
+	if m := yearAfterRegexp.FindStringSubmatch(s); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		return year + 1, 0
+	}
+	if m := singleYearRegexp.FindString(s); len(m) != 0 {
+		year, _ := strconv.Atoi(m)
+		return year, year
+	}
+	return 0, 0
+}
+
+var floorsRegexp = regexp.MustCompile(`\d+`)
+
+// ParseFloors returns the number of floors, 0 when unknown.
+func ParseFloors(s string) int {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "földszintes") {
+		return 1
+	}
+
+	floors, err := strconv.Atoi(floorsRegexp.FindString(s))
+	if err != nil {
+		return 0
+	}
+	return floors
+}
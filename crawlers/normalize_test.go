@@ -0,0 +1,24 @@
+package crawlers
+
+import "testing"
+
+func TestNormalizeNotGivenValues(t *testing.T) {
+	tests := []struct {
+		parking, ac string
+		parkingType ParkingType
+		acType      AirConditioningType
+	}{
+		{"nincs", "nincs", ParkingNone, AirConditioningNo},
+		{"nincs megadva", "nincs megadva", ParkingUnknown, AirConditioningUnknown},
+		{"Nincs megadva", "Nincs megadva", ParkingUnknown, AirConditioningUnknown},
+	}
+
+	for _, tt := range tests {
+		p := PropertyInfo{Parking: tt.parking, AirConditioning: tt.ac}
+		NormalizeProperty(&p, ingatlanComValueMappings)
+		if p.ParkingType != tt.parkingType || p.AirConditioningType != tt.acType {
+			t.Errorf("expected '%s' to be normalized to '%s' and '%s', got '%s' and '%s'",
+				tt.parking, tt.parkingType, tt.acType, p.ParkingType, p.AirConditioningType)
+		}
+	}
+}
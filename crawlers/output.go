@@ -0,0 +1,134 @@
+package crawlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultOutputFormat = "csv"
+
+// OutputWriter writes the grouped crawl results in one file format.
+type OutputWriter interface {
+	// Format is the name of the format in the config and on the command line.
+	Format() string
+	Extension() string
+	Write(w io.Writer, groups []PropertyGroup) error
+}
+
+var outputWriters = map[string]OutputWriter{}
+
+// RegisterOutputWriter makes an output format available under its name.
+// Writers register themselves from init in their own files.
+func RegisterOutputWriter(ow OutputWriter) {
+	name := strings.ToLower(ow.Format())
+	if _, ok := outputWriters[name]; ok {
+		panic(fmt.Sprintf("output format '%s' registered twice", name))
+	}
+	outputWriters[name] = ow
+}
+
+func GetOutputWriter(format string) (OutputWriter, error) {
+	ow, ok := outputWriters[strings.ToLower(strings.TrimSpace(format))]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: '%s'", format)
+	}
+	return ow, nil
+}
+
+// OutputFormats returns the names of all registered output formats in alphabetical order.
+func OutputFormats() []string {
+	names := make([]string, 0, len(outputWriters))
+	for name := range outputWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tableWriter is an OutputWriter taking the column and formatting settings.
+type tableWriter interface {
+	withCsvConfig(cc CsvConfig) OutputWriter
+}
+
+// OutputWriters returns the writers of the formats, csv when none is given.
+// The tabular formats are set up with cc.
+func OutputWriters(formats []string, cc CsvConfig) ([]OutputWriter, error) {
+	if len(formats) == 0 {
+		formats = []string{defaultOutputFormat}
+	}
+
+	var writers []OutputWriter
+	for _, f := range formats {
+		ow, err := GetOutputWriter(f)
+		if err != nil {
+			return nil, err
+		}
+		if tw, ok := ow.(tableWriter); ok {
+			ow = tw.withCsvConfig(cc)
+		}
+		writers = append(writers, ow)
+	}
+	return writers, nil
+}
+
+// OutputFileName replaces the extension of path with the one of the writer.
+func OutputFileName(path string, ow OutputWriter) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ow.Extension()
+}
+
+// WriteOutputFile creates the file at path and writes the groups into it.
+func WriteOutputFile(path string, ow OutputWriter, groups []PropertyGroup) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := ow.Write(f, groups); err != nil {
+		return fmt.Errorf("could not write %s to '%s': %s", ow.Format(), path, err)
+	}
+	return f.Close()
+}
+
+// GroupsOfListings wraps every listing into a group of its own, for writing
+// listings that were not matched across portals.
+func GroupsOfListings(props []PropertyInfo) []PropertyGroup {
+	groups := make([]PropertyGroup, len(props))
+	for i, p := range props {
+		groups[i] = PropertyGroup{Canonical: p, Sources: []PropertyInfo{p}, CheapestPortal: p.Portal}
+	}
+	return groups
+}
+
+func init() {
+	RegisterOutputWriter(CsvWriter{})
+}
+
+// CsvWriter writes one row per property with the columns and formatting of its config.
+type CsvWriter struct {
+	Config CsvConfig
+}
+
+func (CsvWriter) Format() string {
+	return "csv"
+}
+
+func (CsvWriter) Extension() string {
+	return ".csv"
+}
+
+func (cw CsvWriter) Write(w io.Writer, groups []PropertyGroup) error {
+	columns, err := SelectColumns(cw.Config.Columns)
+	if err != nil {
+		return err
+	}
+	return writeCsv(w, cw.Config, columns, groups)
+}
+
+func (CsvWriter) withCsvConfig(cc CsvConfig) OutputWriter {
+	return CsvWriter{Config: cc}
+}
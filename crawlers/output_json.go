@@ -0,0 +1,114 @@
+package crawlers
+
+import (
+	"encoding/json"
+	"io"
+)
+
+func init() {
+	RegisterOutputWriter(JsonWriter{})
+	RegisterOutputWriter(JsonLinesWriter{})
+	RegisterOutputWriter(GeoJsonWriter{})
+}
+
+// jsonProperty is a property as written by the json based formats.
+type jsonProperty struct {
+	PropertyInfo
+	CheapestPortal string
+	Sources        []string // links of every listing of the property
+}
+
+func newJsonProperty(g PropertyGroup) jsonProperty {
+	jp := jsonProperty{PropertyInfo: g.Canonical, CheapestPortal: g.CheapestPortal}
+	for _, s := range g.Sources {
+		jp.Sources = append(jp.Sources, s.Link)
+	}
+	return jp
+}
+
+// JsonWriter writes a single json array of the properties.
+type JsonWriter struct{}
+
+func (JsonWriter) Format() string {
+	return "json"
+}
+
+func (JsonWriter) Extension() string {
+	return ".json"
+}
+
+func (JsonWriter) Write(w io.Writer, groups []PropertyGroup) error {
+	props := make([]jsonProperty, len(groups))
+	for i, g := range groups {
+		props[i] = newJsonProperty(g)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(props)
+}
+
+// JsonLinesWriter writes one json object per line, handy for streaming into other tools.
+type JsonLinesWriter struct{}
+
+func (JsonLinesWriter) Format() string {
+	return "jsonl"
+}
+
+func (JsonLinesWriter) Extension() string {
+	return ".jsonl"
+}
+
+func (JsonLinesWriter) Write(w io.Writer, groups []PropertyGroup) error {
+	enc := json.NewEncoder(w)
+	for _, g := range groups {
+		if err := enc.Encode(newJsonProperty(g)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GeoJsonWriter writes the properties with known coordinates as a GeoJSON
+// FeatureCollection of points, the properties without them are left out.
+type GeoJsonWriter struct{}
+
+type geoJsonFeature struct {
+	Type       string       `json:"type"`
+	Geometry   geoJsonPoint `json:"geometry"`
+	Properties jsonProperty `json:"properties"`
+}
+
+type geoJsonPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // longitude first
+}
+
+func (GeoJsonWriter) Format() string {
+	return "geojson"
+}
+
+func (GeoJsonWriter) Extension() string {
+	return ".geojson"
+}
+
+func (GeoJsonWriter) Write(w io.Writer, groups []PropertyGroup) error {
+	collection := struct {
+		Type     string           `json:"type"`
+		Features []geoJsonFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: []geoJsonFeature{}}
+
+	for _, g := range groups {
+		p := g.Canonical
+		if !p.HasCoordinates() {
+			continue
+		}
+		collection.Features = append(collection.Features, geoJsonFeature{
+			Type:       "Feature",
+			Geometry:   geoJsonPoint{Type: "Point", Coordinates: [2]float64{p.Longitude, p.Latitude}},
+			Properties: newJsonProperty(g),
+		})
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
@@ -0,0 +1,164 @@
+package crawlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterOutputWriter(XlsxWriter{})
+}
+
+// XlsxWriter writes an Excel workbook with the same columns as the csv.
+// Numbers are stored as numbers, the header row is frozen and has an autofilter.
+// Only the column selection and the language of the config apply.
+type XlsxWriter struct {
+	Config CsvConfig
+}
+
+const xlsxSheetName = "Ingatlanok"
+
+func (XlsxWriter) Format() string {
+	return "xlsx"
+}
+
+func (XlsxWriter) Extension() string {
+	return ".xlsx"
+}
+
+func (XlsxWriter) withCsvConfig(cc CsvConfig) OutputWriter {
+	return XlsxWriter{Config: cc}
+}
+
+func (xw XlsxWriter) Write(w io.Writer, groups []PropertyGroup) error {
+	columns, err := SelectColumns(xw.Config.Columns)
+	if err != nil {
+		return err
+	}
+
+	headers := make([]string, len(columns))
+	numeric := make([]bool, len(columns))
+	for i, c := range columns {
+		headers[i] = c.Header(xw.Config.Language)
+		numeric[i] = c.Numeric
+	}
+	rows := [][]string{headers}
+	for _, g := range groups {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = c.get(g)
+		}
+		rows = append(rows, row)
+	}
+	lastCell := xlsxCellName(len(headers)-1, len(rows)-1)
+
+	zw := zip.NewWriter(w)
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xlsxSheetName, xlsxSheetName, xlsxAbsolute(lastCell))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/worksheets/sheet1.xml", xlsxSheet(rows, numeric, lastCell)},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func xlsxSheet(rows [][]string, numeric []bool, lastCell string) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, val := range row {
+			ref := xlsxCellName(c, r)
+			if r == 0 {
+				// style 1 is the bold header font
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr" s="1"><is><t>%s</t></is></c>`, ref, xlsxEscape(val))
+				continue
+			}
+			if numeric[c] {
+				if _, err := strconv.ParseFloat(val, 64); err == nil {
+					fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, val)
+				}
+				continue
+			}
+			if len(val) != 0 {
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xlsxEscape(val))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>`)
+	fmt.Fprintf(&b, `<autoFilter ref="A1:%s"/>`, lastCell)
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+// xlsxCellName returns the A1 style name of the zero based column and row.
+func xlsxCellName(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row+1)
+}
+
+// xlsxAbsolute turns e.g. "X10" into "$X$10".
+func xlsxAbsolute(cell string) string {
+	i := strings.IndexAny(cell, "0123456789")
+	return "$" + cell[:i] + "$" + cell[i:]
+}
+
+func xlsxEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// xlsxWorkbook needs the sheet name twice and the last cell of the autofilter range.
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`<definedNames><definedName name="_xlnm._FilterDatabase" localSheetId="0" hidden="1">'%s'!$A$1:%s</definedName></definedNames>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
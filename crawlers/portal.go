@@ -0,0 +1,173 @@
+package crawlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Portal bundles everything needed to crawl a single real estate site.
+// Extractors hold per-page state, so the portal hands out fresh instances
+// on every call.
+type Portal interface {
+	Name() string
+	BaseUrl() string
+	QueryUrl(s Search) string
+	// ListingId extracts the portal's own identifier of the listing from its url.
+	ListingId(link string) (string, error)
+	NewListingPagesExtractor() ListingPagesExtractor
+	NewLinkCollector() LinkExtractor
+	NewPageDataExtractors() []PageDataExtractor
+	// ValueMappings translate the portal's raw texts to the normalized PropertyInfo fields.
+	ValueMappings() ValueMappings
+}
+
+var portals = map[string]Portal{}
+
+// RegisterPortal makes a portal available for crawling under its name.
+// Portals register themselves from init in their own files.
+func RegisterPortal(p Portal) {
+	name := strings.ToLower(p.Name())
+	if _, ok := portals[name]; ok {
+		panic(fmt.Sprintf("portal '%s' registered twice", name))
+	}
+	portals[name] = p
+}
+
+func GetPortal(name string) (Portal, error) {
+	p, ok := portals[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("unknown portal: '%s'", name)
+	}
+	return p, nil
+}
+
+// PortalNames returns the names of all registered portals in alphabetical order.
+func PortalNames() []string {
+	names := make([]string, 0, len(portals))
+	for name := range portals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// baseUrlSetter is implemented by the portals whose address can be changed,
+// e.g. to crawl a local test server instead of the real site.
+type baseUrlSetter interface {
+	SetBaseUrl(baseUrl string)
+}
+
+// SetPortalBaseUrl points every url of the portal to baseUrl.
+func SetPortalBaseUrl(name, baseUrl string) error {
+	p, err := GetPortal(name)
+	if err != nil {
+		return err
+	}
+	s, ok := p.(baseUrlSetter)
+	if !ok {
+		return fmt.Errorf("the address of portal '%s' cannot be changed", p.Name())
+	}
+	s.SetBaseUrl(baseUrl)
+	return nil
+}
+
+// SetPortalBaseUrls applies SetPortalBaseUrl to every portal in the map.
+func SetPortalBaseUrls(baseUrls map[string]string) error {
+	for name, baseUrl := range baseUrls {
+		if err := SetPortalBaseUrl(name, baseUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnabledPortals returns the portals listed in the search, or every
+// registered portal when the search does not list any.
+func EnabledPortals(s Search) ([]Portal, error) {
+	names := s.Portals
+	if len(names) == 0 {
+		names = PortalNames()
+	}
+
+	var enabled []Portal
+	for _, name := range names {
+		p, err := GetPortal(name)
+		if err != nil {
+			return nil, err
+		}
+		enabled = append(enabled, p)
+	}
+	return enabled, nil
+}
+
+// AbsolutePortalUrl turns a link found on one of the portal's pages into an absolute url.
+func AbsolutePortalUrl(p Portal, link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+	return JoinUri(p.BaseUrl(), link)
+}
+
+// CollectPropertyFromPortal collects the property behind a link found on the
+// portal's listing pages and tags it with the portal and the listing id.
+func CollectPropertyFromPortal(ctx context.Context, p Portal, link string) (PropertyInfo, error) {
+	linkToProp := AbsolutePortalUrl(p, link)
+	prop, err := CollectInfoFromPropertyPage(ctx, linkToProp, pageDataExtractors(p)...)
+
+	prop.Portal = p.Name()
+	id, idErr := p.ListingId(linkToProp)
+	if idErr != nil {
+		log.Printf("could not extract listing id from '%s', falling back to the url: %s", linkToProp, idErr)
+	}
+	prop.ListingId = id
+	NormalizeProperty(&prop, p.ValueMappings())
+
+	return prop, err
+}
+
+// ListingKeyForLink returns the key the listing behind the link will be
+// stored with, without fetching it.
+func ListingKeyForLink(p Portal, link string) ListingKey {
+	linkToProp := AbsolutePortalUrl(p, link)
+	id, err := p.ListingId(linkToProp)
+	if err != nil {
+		return ListingKey{Portal: p.Name(), Id: linkToProp}
+	}
+	return ListingKey{Portal: p.Name(), Id: id}
+}
+
+// UniqueListingLinks drops the links pointing to an already seen listing.
+func UniqueListingLinks(p Portal, links []string) []string {
+	seen := make(map[ListingKey]bool)
+	var unique []string
+	for _, l := range links {
+		key := ListingKeyForLink(p, l)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, l)
+	}
+	return unique
+}
+
+// lastPathSegmentMatching returns the first submatch of re in the last segment of the url's path.
+func lastPathSegmentMatching(rawUrl string, re *regexp.Regexp) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+
+	segment := path.Base(strings.TrimRight(u.Path, "/"))
+	m := re.FindStringSubmatch(segment)
+	if m == nil {
+		return "", fmt.Errorf("no listing id in '%s'", rawUrl)
+	}
+	return m[1], nil
+}
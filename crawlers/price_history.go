@@ -0,0 +1,116 @@
+package crawlers
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PricePoint is the price of a listing from the given time on. For rentals
+// Price is the monthly rent in HUF.
+type PricePoint struct {
+	Since            time.Time `json:"since"`
+	Price            float64   `json:"price"`
+	PricePerSqrMeter float64   `json:"price_per_sqr_meter"`
+}
+
+// recordPrice appends a new point to the history when the price differs from
+// the last known one. A corrected area alone changes only the price per m2,
+// that is not a price change.
+func recordPrice(history []PricePoint, p PropertyInfo, seenAt time.Time) []PricePoint {
+	if len(history) != 0 && history[len(history)-1].Price == p.ListedPrice() {
+		return history
+	}
+	return append(history, PricePoint{Since: seenAt, Price: p.ListedPrice(), PricePerSqrMeter: p.PricePerSqrMeter})
+}
+
+// priceHistoryFromVersions rebuilds the history for listings stored before
+// prices were tracked separately.
+func priceHistoryFromVersions(versions []ListingVersion) []PricePoint {
+	var history []PricePoint
+	for _, v := range versions {
+		history = recordPrice(history, v.Property, v.FirstSeen)
+	}
+	return history
+}
+
+// PriceChange summarizes how the price of a listing moved over its lifetime.
+type PriceChange struct {
+	Key                  ListingKey
+	Link, Address        string
+	FirstPrice, OldPrice float64
+	NewPrice             float64
+	Delta, DeltaPercent  float64 // latest change, OldPrice -> NewPrice
+	TotalDelta           float64 // FirstPrice -> NewPrice
+	NumOfChanges         int
+	ChangedAt            time.Time
+	DaysOnMarket         int
+	NewPricePerSqrMeter  float64
+	OldPricePerSqrMeter  float64
+}
+
+// PriceChanges returns the listings whose price changed at or after since,
+// the biggest drop first. A zero since returns every listing that ever changed its price.
+func PriceChanges(listings []StoredListing, since time.Time) []PriceChange {
+	var changes []PriceChange
+	for _, l := range listings {
+		n := len(l.Prices)
+		if n < 2 || l.Prices[n-1].Since.Before(since) {
+			continue
+		}
+
+		first, old, latest := l.Prices[0], l.Prices[n-2], l.Prices[n-1]
+		p := l.Latest()
+		change := PriceChange{
+			Key:                 l.Key,
+			Link:                p.Link,
+			Address:             p.Address,
+			FirstPrice:          first.Price,
+			OldPrice:            old.Price,
+			NewPrice:            latest.Price,
+			Delta:               latest.Price - old.Price,
+			TotalDelta:          latest.Price - first.Price,
+			NumOfChanges:        n - 1,
+			ChangedAt:           latest.Since,
+			DaysOnMarket:        l.DaysOnMarket(),
+			OldPricePerSqrMeter: old.PricePerSqrMeter,
+			NewPricePerSqrMeter: latest.PricePerSqrMeter,
+		}
+		if old.Price != 0 {
+			change.DeltaPercent = change.Delta / old.Price * 100
+		}
+		changes = append(changes, change)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].DeltaPercent < changes[j].DeltaPercent
+	})
+	return changes
+}
+
+// DaysOnMarket is the number of days between the first and the last time the listing was seen.
+func (l StoredListing) DaysOnMarket() int {
+	return int(math.Floor(l.LastSeen.Sub(l.FirstSeen).Hours() / 24))
+}
+
+func WritePriceChangesAsCsv(w io.Writer, changes []PriceChange) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Portál", "Cím", "URL", "Régi ár", "Új ár", "Változás", "Változás (%)", "Első ár", "Teljes változás", "Árváltozások száma", "Változás ideje", "Napok a piacon", "Régi négyzetméter ár", "Új négyzetméter ár"})
+	for _, c := range changes {
+		writer.Write([]string{c.Key.Portal, c.Address, c.Link,
+			formatFloat(c.OldPrice), formatFloat(c.NewPrice), formatFloat(c.Delta), formatFloat(c.DeltaPercent),
+			formatFloat(c.FirstPrice), formatFloat(c.TotalDelta), strconv.Itoa(c.NumOfChanges),
+			c.ChangedAt.Format("2006-01-02"), strconv.Itoa(c.DaysOnMarket),
+			formatFloat(c.OldPricePerSqrMeter), formatFloat(c.NewPricePerSqrMeter)})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
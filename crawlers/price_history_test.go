@@ -0,0 +1,25 @@
+package crawlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordPriceIgnoresAreaCorrections(t *testing.T) {
+	day := time.Date(2021, 9, 1, 8, 0, 0, 0, time.UTC)
+	p := PropertyInfo{Portal: "ingatlan.com", ListingId: "1", Price: 60, HouseArea: 100, PricePerSqrMeter: 600000}
+
+	history := recordPrice(nil, p, day)
+	p.HouseArea, p.PricePerSqrMeter = 120, 500000
+	history = recordPrice(history, p, day.Add(24*time.Hour))
+	if len(history) != 1 {
+		t.Fatalf("expected a corrected area not to add a price point, got %+v", history)
+	}
+
+	p.Price, p.PricePerSqrMeter = 55, 458333
+	history = recordPrice(history, p, day.Add(48*time.Hour))
+	changes := PriceChanges([]StoredListing{{Key: p.Key(), Prices: history}}, time.Time{})
+	if len(changes) != 1 || changes[0].Delta != -5 || changes[0].NumOfChanges != 1 || changes[0].NewPricePerSqrMeter != 458333 {
+		t.Errorf("expected one change of -5 with the new price per m2, got %+v", changes)
+	}
+}
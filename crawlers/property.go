@@ -1,34 +1,77 @@
 package crawlers
 
-import (
-	"strconv"
-)
-
 type PropertyInfo struct {
+	Search, Portal, ListingId                                                                            string
 	Address, Link, Condition, Parking, BuiltIn, NumOfFloors, Heating, AirConditioning, ToiletAndBathroom string
 	HouseArea, LotArea, NumOfRooms                                                                       int
 	Price, PricePerSqrMeter                                                                              float64
+
+	// normalized from the raw strings above, see NormalizeProperty
+	ConditionCategory                  ConditionCategory
+	HeatingType                        HeatingType
+	ParkingType                        ParkingType
+	AirConditioningType                AirConditioningType
+	BuildYearFrom, BuildYearTo, Floors int
+
+	// Transaction is only set for rentals, the rent fields are in HUF and
+	// Price stays 0 for them.
+	Transaction                     TransactionType
+	MonthlyRent, Deposit            float64
+	UtilitiesIncluded, MinLeaseTerm string
+
+	// WGS84 coordinates when the page shows a map, 0 otherwise
+	Latitude, Longitude float64
+}
+
+// Key identifies the listing in the store. Listings without a portal id are keyed by their url.
+func (pi PropertyInfo) Key() ListingKey {
+	if len(pi.ListingId) == 0 {
+		return ListingKey{Portal: pi.Portal, Id: pi.Link}
+	}
+	return ListingKey{Portal: pi.Portal, Id: pi.ListingId}
 }
 
+// GetHeaders returns the Hungarian headers of the listing columns, see Columns.
 func (pi PropertyInfo) GetHeaders() []string {
-	return []string{"Cím", "URL", "Állapot", "Parkolás", "Építés éve", "Emeletek száma", "Fűtés", "Légkondicionálás", "WC/Fürdő", "Alapterület", "Telekterület", "Szobák száma", "Ár", "Négyzetméter Ár"}
+	columns := listingColumns()
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.Hu
+	}
+	return headers
 }
 
+// ToSlice returns the values of the listing columns in the order of GetHeaders.
 func (pi PropertyInfo) ToSlice() []string {
-	return []string{pi.Address, pi.Link, pi.Condition, pi.Parking, pi.BuiltIn,
-		pi.NumOfFloors, pi.Heating, pi.AirConditioning, pi.ToiletAndBathroom,
-		strconv.Itoa(pi.HouseArea), strconv.Itoa(pi.LotArea), strconv.Itoa(pi.NumOfRooms),
-		strconv.FormatFloat(pi.Price, 'f', 2, 64), strconv.FormatFloat(pi.PricePerSqrMeter, 'f', 2, 64)}
+	columns := listingColumns()
+	g := PropertyGroup{Canonical: pi}
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = c.get(g)
+	}
+	return values
 }
 
-// is it too much memory to copy the list? probably not
+// IsPropPresentInList reports whether the same listing is already in the list.
 func IsPropPresentInList(l []PropertyInfo, p PropertyInfo) bool {
 	for _, prop := range l {
-		if prop.HouseArea == p.HouseArea &&
-			prop.LotArea == p.LotArea &&
-			prop.Price == p.Price {
+		if prop.Key() == p.Key() {
 			return true
 		}
 	}
 	return false
 }
+
+// UniqueProperties keeps the first occurrence of every listing.
+func UniqueProperties(props []PropertyInfo) []PropertyInfo {
+	seen := make(map[ListingKey]bool, len(props))
+	var unique []PropertyInfo
+	for _, p := range props {
+		if seen[p.Key()] {
+			continue
+		}
+		seen[p.Key()] = true
+		unique = append(unique, p)
+	}
+	return unique
+}
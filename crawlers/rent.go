@@ -0,0 +1,131 @@
+package crawlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TransactionType tells whether a search looks for properties for sale or for rent.
+// The values are the slugs the portals use in their urls.
+type TransactionType string
+
+const (
+	Sale TransactionType = "elado"
+	Rent TransactionType = "kiado"
+)
+
+// OrDefault returns Sale for an unset transaction type.
+func (t TransactionType) OrDefault() TransactionType {
+	if len(t) == 0 {
+		return Sale
+	}
+	return t
+}
+
+// IsRental reports whether the property was advertised for rent.
+func (pi PropertyInfo) IsRental() bool {
+	return pi.Transaction == Rent
+}
+
+// ListedPrice is the price to compare listings by: the price in million
+// HUF for sales and the monthly rent in HUF for rentals.
+func (pi PropertyInfo) ListedPrice() float64 {
+	if pi.IsRental() {
+		return pi.MonthlyRent
+	}
+	return pi.Price
+}
+
+var (
+	priceNumberRegexp = regexp.MustCompile(`^\d[\d .,]*`)
+	// thousandsRegexp matches numbers grouped by '.' or space, "250.000" or
+	// "1 250 000,5"; a '.' followed by other than three digits is a decimal point.
+	thousandsRegexp = regexp.MustCompile(`^\d{1,3}(?:[ .]\d{3})+(?:,\d+)?$`)
+)
+
+// parsePriceText parses prices as the portals show them, e.g. "85 M Ft",
+// "85,5 millió Ft", "1,5 Mrd Ft", "250 ezer Ft/hó", "250 000 Ft/hó" or
+// "250.000 Ft/hó". The amount is returned in HUF. Texts without a currency
+// are taken as million HUF, as sale prices used to be shown that way.
+func parsePriceText(s string) (huf float64, monthly bool, err error) {
+	s = strings.ToLower(strings.TrimSpace(strings.Replace(s, " ", " ", -1)))
+
+	number := priceNumberRegexp.FindString(s)
+	if len(number) == 0 {
+		return 0, false, fmt.Errorf("no amount in price '%s'", s)
+	}
+	rest := strings.TrimSpace(s[len(number):])
+
+	number = strings.TrimRight(number, " .,")
+	if thousandsRegexp.MatchString(number) {
+		number = strings.NewReplacer(" ", "", ".", "").Replace(number)
+	}
+	number = strings.Replace(number, " ", "", -1)
+	number = strings.Replace(number, ",", ".", 1)
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("could not convert '%s' to float", number)
+	}
+
+	switch {
+	case strings.HasPrefix(rest, "mrd"), strings.HasPrefix(rest, "milliárd"):
+		amount *= 1000000000
+	case strings.HasPrefix(rest, "m"):
+		amount *= 1000000
+	case strings.HasPrefix(rest, "ezer"), strings.HasPrefix(rest, "e ft"):
+		amount *= 1000
+	case !strings.Contains(rest, "ft"):
+		amount *= 1000000
+	}
+
+	monthly = strings.Contains(rest, "/hó") || strings.Contains(rest, "/ hó") || strings.Contains(rest, "havonta")
+	return amount, monthly, nil
+}
+
+// depositInHuf converts the deposit as the portals show it to HUF: either an
+// amount ("500 000 Ft", "500 ezer Ft") or a number of monthly rents ("2 havi").
+// Unparsable texts are logged and give 0.
+func depositInHuf(s string, monthlyRent float64) float64 {
+	if len(strings.TrimSpace(s)) == 0 {
+		return 0
+	}
+
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "havi") || strings.Contains(lower, "hónap") {
+		months, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(priceNumberRegexp.FindString(lower)), ",", ".", 1), 64)
+		if err != nil {
+			log.Printf("could not parse deposit '%s': %s", s, err)
+			return 0
+		}
+		return months * monthlyRent
+	}
+	if !strings.Contains(lower, "ft") {
+		lower += " ft" // a bare number is in HUF, unlike sale prices
+	}
+
+	huf, _, err := parsePriceText(lower)
+	if err != nil {
+		log.Printf("could not parse deposit '%s': %s", s, err)
+		return 0
+	}
+	return huf
+}
+
+// setListedPrice stores a parsed price either as sale price or as monthly rent.
+func setListedPrice(huf float64, monthly bool, price, monthlyRent *float64) {
+	if monthly {
+		*monthlyRent = huf
+		return
+	}
+	*price = huf / 1000000
+}
+
+func formatListedPrice(p PropertyInfo) string {
+	if p.IsRental() {
+		return fmt.Sprintf("%.0f Ft/hó", p.MonthlyRent)
+	}
+	return fmt.Sprintf("%.2f M Ft", p.Price)
+}
@@ -0,0 +1,56 @@
+package crawlers
+
+import "testing"
+
+func TestParsePriceText(t *testing.T) {
+	tests := []struct {
+		text    string
+		huf     float64
+		monthly bool
+	}{
+		{"85 M Ft", 85000000, false},
+		{"85,5 millió Ft", 85500000, false},
+		{"1.5 M Ft", 1500000, false},
+		{"1,5 Mrd Ft", 1500000000, false},
+		{"2,5 milliárd Ft", 2500000000, false},
+		{"89.9", 89900000, false},
+		{"250 ezer Ft/hó", 250000, true},
+		{"250 000 Ft/hó", 250000, true},
+		{"250 000 Ft / hó", 250000, true},
+		{"250.000 Ft/hó", 250000, true},
+		{"1.250.000 Ft havonta", 1250000, true},
+		{"1 250 000,5 Ft", 1250000.5, false},
+		{"500.000 Ft", 500000, false},
+	}
+
+	for _, tt := range tests {
+		huf, monthly, err := parsePriceText(tt.text)
+		if err != nil {
+			t.Errorf("'%s': %s", tt.text, err)
+			continue
+		}
+		if huf != tt.huf || monthly != tt.monthly {
+			t.Errorf("'%s': expected %v HUF (monthly: %v), got %v HUF (monthly: %v)", tt.text, tt.huf, tt.monthly, huf, monthly)
+		}
+	}
+}
+
+func TestDepositInHuf(t *testing.T) {
+	tests := []struct {
+		text string
+		huf  float64
+	}{
+		{"2 havi", 500000},
+		{"500 000 Ft", 500000},
+		{"500.000 Ft", 500000},
+		{"500 ezer Ft", 500000},
+		{"500000", 500000},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if huf := depositInHuf(tt.text, 250000); huf != tt.huf {
+			t.Errorf("'%s': expected %v HUF, got %v", tt.text, tt.huf, huf)
+		}
+	}
+}
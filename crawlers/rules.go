@@ -0,0 +1,356 @@
+package crawlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// ExtractionRule tells where a field of the property is on the page and
+// how to read it. Rules let a broken selector be fixed in a file instead of
+// in the code.
+type ExtractionRule struct {
+	// Field is the key of the column the value goes into, see ColumnKeys.
+	Field string `json:"mező"`
+	// Selector is the css selector of the element holding the value. When
+	// Labels are given it selects the label elements instead, and the value
+	// is the element right after the label having one of the texts.
+	Selector string   `json:"selektor"`
+	Labels   []string `json:"címkék,omitempty"`
+	// ValueSelector narrows the value down inside the element found, e.g. "span".
+	ValueSelector string `json:"érték_selektor,omitempty"`
+	// Attribute is read instead of the text of the element.
+	Attribute string `json:"attribútum,omitempty"`
+	// Parser turns the text into the value of the field, see RuleParsers.
+	Parser string `json:"értelmező,omitempty"`
+}
+
+// RuleParsers are the accepted values of ExtractionRule.Parser:
+//
+//	text       the text as is (default)
+//	int        the first whole number, "5 szoba" -> 5
+//	float      the first number with '.' or ',' decimals
+//	area-m2    an area, "55,5 m²" -> 55
+//	price-mFt  a price into million HUF, "89,9 M Ft"; monthly rents like
+//	           "250 ezer Ft/hó" go into monthly_rent in HUF instead
+//	price-Ft   a price in HUF, "260 000 Ft/hó" -> 260000
+//	deposit    a deposit in HUF, "2 havi" is multiplied by the monthly rent
+var RuleParsers = []string{"text", "int", "float", "area-m2", "price-mFt", "price-Ft", "deposit"}
+
+type compiledRule struct {
+	ExtractionRule
+	column        Column
+	selector      cascadia.Selector
+	valueSelector cascadia.Selector
+}
+
+// compileRules checks the rules and prepares their selectors, reporting every
+// problem with the path of the rule under path.
+func compileRules(path string, rules []ExtractionRule) ([]compiledRule, error) {
+	v := &validator{}
+	compiled := make([]compiledRule, len(rules))
+	for i, r := range rules {
+		rulePath := fmt.Sprintf("%s[%d].", path, i)
+		if len(r.Parser) == 0 {
+			r.Parser = "text"
+		}
+		cr := compiledRule{ExtractionRule: r}
+
+		if c, ok := columnByKey(r.Field); ok && c.set != nil {
+			cr.column = c
+		} else {
+			v.addf(rulePath+"mező", "unknown field '%s'", r.Field)
+		}
+		v.oneOf(rulePath+"értelmező", r.Parser, RuleParsers)
+
+		sel, err := cascadia.Compile(r.Selector)
+		if err != nil {
+			v.addf(rulePath+"selektor", "invalid selector '%s': %s", r.Selector, err)
+		}
+		cr.selector = sel
+		if len(r.ValueSelector) != 0 {
+			sel, err := cascadia.Compile(r.ValueSelector)
+			if err != nil {
+				v.addf(rulePath+"érték_selektor", "invalid selector '%s': %s", r.ValueSelector, err)
+			}
+			cr.valueSelector = sel
+		}
+		compiled[i] = cr
+	}
+
+	if len(v.errs) != 0 {
+		return nil, v.errs
+	}
+	return compiled, nil
+}
+
+// find returns the raw value of the rule on the page.
+func (r compiledRule) find(doc *html.Node) (string, bool) {
+	var value *html.Node
+	if len(r.Labels) == 0 {
+		value = r.selector.MatchFirst(doc)
+	} else {
+		for _, label := range r.selector.MatchAll(doc) {
+			if r.hasLabel(nodeText(label)) {
+				value = nextElementSibling(label)
+				break
+			}
+		}
+	}
+	if value != nil && r.valueSelector != nil {
+		value = r.valueSelector.MatchFirst(value)
+	}
+	if value == nil {
+		return "", false
+	}
+
+	if len(r.Attribute) != 0 {
+		for _, attr := range value.Attr {
+			if attr.Key == r.Attribute {
+				return strings.TrimSpace(attr.Val), true
+			}
+		}
+		return "", false
+	}
+	return nodeText(value), true
+}
+
+func (r compiledRule) hasLabel(text string) bool {
+	for _, l := range r.Labels {
+		if text == strings.TrimSpace(l) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	wholeNumberRegexp = regexp.MustCompile(`\d{1,3}(?:[ \x{a0}]\d{3})+|\d+`)
+	decimalRegexp     = regexp.MustCompile(`-?\d+(?:[.,]\d+)?`)
+)
+
+// apply parses the raw value into the field of the rule. Deposits are left
+// to the caller as they depend on the rent.
+func (r compiledRule) apply(p *PropertyInfo, raw string) error {
+	column, value := r.column, raw
+	switch r.Parser {
+	case "int":
+		n := wholeNumberRegexp.FindString(raw)
+		if len(n) == 0 {
+			return fmt.Errorf("no number in '%s'", raw)
+		}
+		value = strings.NewReplacer(" ", "", "\u00a0", "").Replace(n)
+	case "float", "area-m2":
+		n := decimalRegexp.FindString(raw)
+		if len(n) == 0 {
+			return fmt.Errorf("no number in '%s'", raw)
+		}
+		f, err := strconv.ParseFloat(strings.Replace(n, ",", ".", 1), 64)
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+		if r.Parser == "area-m2" {
+			value = strconv.Itoa(int(f))
+		}
+	case "price-mFt", "price-Ft":
+		huf, monthly, err := parsePriceText(raw)
+		if err != nil {
+			return err
+		}
+		if r.Parser == "price-mFt" && !monthly {
+			huf /= 1000000
+		} else if r.Parser == "price-mFt" {
+			column, _ = columnByKey("monthly_rent")
+		}
+		value = strconv.FormatFloat(huf, 'f', -1, 64)
+	}
+	return column.set(p, value)
+}
+
+// RuleExtractor is a PageDataExtractor driven by extraction rules instead of code.
+// Rules are tried in order, a field found by an earlier rule is not overwritten,
+// so later rules can serve as fallbacks.
+type RuleExtractor struct {
+	rules []compiledRule
+	found []foundValue
+	done  bool
+}
+
+type foundValue struct {
+	rule compiledRule
+	raw  string
+}
+
+func (e *RuleExtractor) Predicate(n *html.Node) bool {
+	return n.Type == html.DocumentNode
+}
+
+func (e *RuleExtractor) ProcessNode(n *html.Node) {
+	for _, r := range e.rules {
+		if raw, ok := r.find(n); ok {
+			e.found = append(e.found, foundValue{rule: r, raw: raw})
+		}
+	}
+	e.done = true
+}
+
+// Done reports whether the rules were run, they search the whole document at once.
+func (e *RuleExtractor) Done() bool {
+	return e.done
+}
+
+func (e *RuleExtractor) AddInfoIntoProp(p *PropertyInfo) {
+	set := make(map[string]bool)
+	var deposit string
+	for _, f := range e.found {
+		if set[f.rule.Field] {
+			continue
+		}
+		if f.rule.Parser == "deposit" {
+			deposit, set[f.rule.Field] = f.raw, true
+			continue
+		}
+		if err := f.rule.apply(p, f.raw); err != nil {
+			log.Printf("could not read '%s' from '%s': %s", f.rule.Field, f.raw, err)
+			continue
+		}
+		set[f.rule.Field] = true
+	}
+
+	// values depending on others are worked out once everything is known
+	if len(deposit) != 0 {
+		p.Deposit = depositInHuf(deposit, p.MonthlyRent)
+	}
+	if !set["price_per_sqr_meter"] && p.HouseArea > 0 {
+		if p.MonthlyRent > 0 {
+			p.PricePerSqrMeter = p.MonthlyRent / float64(p.HouseArea)
+		} else {
+			p.PricePerSqrMeter = (p.Price / float64(p.HouseArea)) * 1000000.0
+		}
+	}
+}
+
+var portalRules = map[string][]compiledRule{}
+
+// SetExtractionRules makes the portal use the rules instead of its built-in
+// extractors. Not safe to call while crawling.
+func SetExtractionRules(portal string, rules []ExtractionRule) error {
+	p, err := GetPortal(portal)
+	if err != nil {
+		return err
+	}
+	compiled, err := compileRules(p.Name(), rules)
+	if err != nil {
+		return err
+	}
+	portalRules[p.Name()] = compiled
+	return nil
+}
+
+// ResetExtractionRules switches every portal back to its built-in extractors.
+func ResetExtractionRules() {
+	portalRules = map[string][]compiledRule{}
+}
+
+// pageDataExtractors returns the extractors of a property page of the portal.
+func pageDataExtractors(p Portal) []PageDataExtractor {
+	if rules, ok := portalRules[p.Name()]; ok {
+		return []PageDataExtractor{&RuleExtractor{rules: rules}}
+	}
+	return p.NewPageDataExtractors()
+}
+
+// LoadExtractionRules reads a json, yaml or toml rules file holding the rules
+// of each portal under its name, and checks every rule in it.
+func LoadExtractionRules(path string) (map[string][]ExtractionRule, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeConfigDocument(file, strings.ToLower(filepath.Ext(path)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	asJson, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return parseExtractionRules(path, asJson)
+}
+
+func parseExtractionRules(path string, data []byte) (map[string][]ExtractionRule, error) {
+	var rules map[string][]ExtractionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	var portals []string
+	for portal := range rules {
+		portals = append(portals, portal)
+	}
+	sort.Strings(portals)
+
+	var errs ValidationErrors
+	for _, portal := range portals {
+		if _, err := GetPortal(portal); err != nil {
+			errs = append(errs, ValidationError{Field: portal, Message: fmt.Sprintf("%s, known portals: %s", err, strings.Join(PortalNames(), ", "))})
+			continue
+		}
+		if _, err := compileRules(portal, rules[portal]); err != nil {
+			errs = append(errs, err.(ValidationErrors)...)
+		}
+	}
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("%s: %w", path, errs)
+	}
+	return rules, nil
+}
+
+//go:embed extraction_rules.json
+var defaultExtractionRules []byte
+
+// DefaultExtractionRules returns the rules doing the same as the built-in
+// extractors of the portals, the starting point of a rules file.
+func DefaultExtractionRules() map[string][]ExtractionRule {
+	rules, err := parseExtractionRules("extraction_rules.json", defaultExtractionRules)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// nodeText returns the text inside the node with the whitespace collapsed.
+func nodeText(n *html.Node) string {
+	var parts []string
+	var f func(n *html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			parts = append(parts, n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+func nextElementSibling(n *html.Node) *html.Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package crawlers
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers           = 8
+	defaultRequestsPerSecond = 2.0
+	defaultMaxInFlight       = 4
+)
+
+// RateLimit describes how hard a single host may be hit. Zero values fall
+// back to the defaults.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"kérés_per_mp"`
+	MaxInFlight       int     `json:"max_párhuzamos"`
+}
+
+func (rl RateLimit) withDefaults() RateLimit {
+	if rl.RequestsPerSecond <= 0 {
+		rl.RequestsPerSecond = defaultRequestsPerSecond
+	}
+	if rl.MaxInFlight <= 0 {
+		rl.MaxInFlight = defaultMaxInFlight
+	}
+	return rl
+}
+
+// Task is a unit of work that fetches the given url.
+type Task struct {
+	Url string
+	Run func(ctx context.Context)
+}
+
+// Scheduler runs tasks on a fixed size worker pool, limiting the request
+// rate and the number of requests in flight separately for every host.
+type Scheduler struct {
+	workers int
+
+	mu       sync.Mutex
+	limits   map[string]RateLimit
+	limiters map[string]*hostLimiter
+}
+
+func NewScheduler(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	return &Scheduler{
+		workers:  workers,
+		limits:   make(map[string]RateLimit),
+		limiters: make(map[string]*hostLimiter),
+	}
+}
+
+// SetHostLimit overrides the default rate limit for the host of the given url.
+func (s *Scheduler) SetHostLimit(rawUrl string, rl RateLimit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := hostOf(rawUrl)
+	s.limits[host] = rl.withDefaults()
+	delete(s.limiters, host)
+}
+
+// Run executes all tasks and returns when every one of them has finished.
+// Tasks of different hosts are interleaved so one slow host does not hold up the rest.
+// When the context is cancelled the tasks not started yet are dropped, Run
+// waits for the running ones and returns the context's error.
+func (s *Scheduler) Run(ctx context.Context, tasks []Task) error {
+	queue := make(chan Task)
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				l := s.limiterFor(t.Url)
+				if err := l.acquire(ctx, t.Url); err != nil {
+					continue
+				}
+				t.Run(ctx)
+				l.release()
+			}
+		}()
+	}
+
+dispatch:
+	for _, t := range interleaveByHost(tasks) {
+		select {
+		case queue <- t:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (s *Scheduler) limiterFor(rawUrl string) *hostLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host := hostOf(rawUrl)
+	if l, ok := s.limiters[host]; ok {
+		return l
+	}
+
+	rl, ok := s.limits[host]
+	if !ok {
+		rl = RateLimit{}.withDefaults()
+	}
+	l := newHostLimiter(rl)
+	s.limiters[host] = l
+	return l
+}
+
+func interleaveByHost(tasks []Task) []Task {
+	var hosts []string
+	byHost := make(map[string][]Task)
+	for _, t := range tasks {
+		host := hostOf(t.Url)
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], t)
+	}
+
+	interleaved := make([]Task, 0, len(tasks))
+	for len(interleaved) < len(tasks) {
+		for _, host := range hosts {
+			if len(byHost[host]) == 0 {
+				continue
+			}
+			interleaved = append(interleaved, byHost[host][0])
+			byHost[host] = byHost[host][1:]
+		}
+	}
+	return interleaved
+}
+
+func hostOf(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	return u.Host
+}
+
+// hostLimiter is a token bucket combined with a semaphore for the requests in flight.
+type hostLimiter struct {
+	inFlight chan struct{}
+
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newHostLimiter(rl RateLimit) *hostLimiter {
+	burst := rl.RequestsPerSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &hostLimiter{
+		inFlight: make(chan struct{}, rl.MaxInFlight),
+		rate:     rl.RequestsPerSecond,
+		burst:    burst,
+		tokens:   burst,
+		lastFill: time.Now(),
+	}
+}
+
+// acquire blocks until the request to the url may be sent. On error no slot is held.
+func (l *hostLimiter) acquire(ctx context.Context, rawUrl string) error {
+	select {
+	case l.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for {
+		wait := l.takeToken()
+		if wait == 0 {
+			return nil
+		}
+		verbosef("rate limit of %s, waiting %s\n", rawUrl, wait.Round(time.Millisecond))
+		if err := sleepContext(ctx, wait); err != nil {
+			l.release()
+			return err
+		}
+	}
+}
+
+func (l *hostLimiter) release() {
+	<-l.inFlight
+}
+
+// takeToken takes a token from the bucket if there is one, otherwise it
+// returns how long to wait until the next token is available.
+func (l *hostLimiter) takeToken() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.lastFill).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.lastFill = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if wait < time.Millisecond {
+		wait = time.Millisecond
+	}
+	return wait
+}
@@ -0,0 +1,184 @@
+package crawlers
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ListingKey identifies a listing on a given portal across runs.
+type ListingKey struct {
+	Portal string `json:"portal"`
+	Id     string `json:"id"`
+}
+
+func (k ListingKey) String() string {
+	return k.Portal + "/" + k.Id
+}
+
+// ListingVersion is the data of a listing as observed between FirstSeen
+// and LastSeen without any change.
+type ListingVersion struct {
+	FirstSeen time.Time    `json:"first_seen"`
+	LastSeen  time.Time    `json:"last_seen"`
+	Property  PropertyInfo `json:"property"`
+}
+
+// StoredListing is a listing with every version of it observed so far,
+// the oldest one first.
+type StoredListing struct {
+	Key       ListingKey       `json:"key"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+	Versions  []ListingVersion `json:"versions"`
+	Prices    []PricePoint     `json:"prices"`
+	Searches  []string         `json:"searches"` // names of the searches that found the listing
+}
+
+func (l StoredListing) Latest() PropertyInfo {
+	if len(l.Versions) == 0 {
+		return PropertyInfo{}
+	}
+	return l.Versions[len(l.Versions)-1].Property
+}
+
+// VersionAt returns the data of the listing as it was at the given time.
+func (l StoredListing) VersionAt(t time.Time) (PropertyInfo, bool) {
+	for i := len(l.Versions) - 1; i >= 0; i-- {
+		if !l.Versions[i].FirstSeen.After(t) {
+			return l.Versions[i].Property, true
+		}
+	}
+	return PropertyInfo{}, false
+}
+
+// CrawlRun records which listings were seen by a crawl and how well they were extracted.
+type CrawlRun struct {
+	StartedAt   time.Time `json:"started_at"`
+	Interrupted bool      `json:"interrupted"`
+	// Searches are the names of the searches run, only runs of the same
+	// searches are compared.
+	Searches []string     `json:"searches,omitempty"`
+	Keys     []ListingKey `json:"keys"`
+	// FailedKeys are the listings found but not fetched, e.g. on a timeout.
+	// They are still on the portal, so they do not count as removed.
+	FailedKeys []ListingKey `json:"failed_keys,omitempty"`
+
+	// FillRates are the extraction statistics of the run by portal, the
+	// baseline of the next run's health check.
+	FillRates map[string]FillRates `json:"fill_rates,omitempty"`
+}
+
+// SameSearches reports whether the run covered exactly the given searches.
+func (r CrawlRun) SameSearches(searches []string) bool {
+	if len(r.Searches) != len(searches) {
+		return false
+	}
+	covered := make(map[string]bool, len(r.Searches))
+	for _, s := range r.Searches {
+		covered[s] = true
+	}
+	for _, s := range searches {
+		if !covered[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// Store persists the listings between runs.
+type Store interface {
+	// Upsert records that the property was seen at the given time. A new
+	// version is only added when the data differs from the latest one.
+	Upsert(key ListingKey, p PropertyInfo, seenAt time.Time) error
+	Get(key ListingKey) (StoredListing, bool, error)
+	Listings() ([]StoredListing, error)
+	AddRun(run CrawlRun) error
+	// Runs returns every recorded run, the oldest one first.
+	Runs() ([]CrawlRun, error)
+	Close() error
+}
+
+// upsertListing applies an observation to the listing and returns the updated listing.
+// The same listing may be found by several searches, so the search is
+// collected on the listing instead of being part of the versions.
+func upsertListing(l StoredListing, key ListingKey, p PropertyInfo, seenAt time.Time) StoredListing {
+	search := p.Search
+	p.Search = ""
+
+	if len(l.Versions) == 0 {
+		return StoredListing{
+			Key:       key,
+			FirstSeen: seenAt,
+			LastSeen:  seenAt,
+			Versions:  []ListingVersion{{FirstSeen: seenAt, LastSeen: seenAt, Property: p}},
+			Prices:    recordPrice(nil, p, seenAt),
+			Searches:  addSearchName(nil, search),
+		}
+	}
+
+	l.Searches = addSearchName(l.Searches, search)
+
+	if seenAt.After(l.LastSeen) {
+		l.LastSeen = seenAt
+	}
+	if len(l.Prices) == 0 {
+		l.Prices = priceHistoryFromVersions(l.Versions)
+	}
+	l.Prices = recordPrice(l.Prices, p, seenAt)
+
+	latest := &l.Versions[len(l.Versions)-1]
+	if reflect.DeepEqual(latest.Property, p) {
+		if seenAt.After(latest.LastSeen) {
+			latest.LastSeen = seenAt
+		}
+		return l
+	}
+
+	l.Versions = append(l.Versions, ListingVersion{FirstSeen: seenAt, LastSeen: seenAt, Property: p})
+	return l
+}
+
+func addSearchName(names []string, name string) []string {
+	if len(name) == 0 {
+		return names
+	}
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// migrateUrlKey re-keys a listing stored before listing ids were extracted,
+// when the portal can derive the id from the stored url. The returned bool
+// reports whether the listing was changed.
+func migrateUrlKey(l StoredListing) (StoredListing, bool) {
+	if len(l.Versions) == 0 || len(l.Latest().ListingId) != 0 {
+		return l, false
+	}
+	p, err := GetPortal(l.Key.Portal)
+	if err != nil {
+		return l, false
+	}
+	id, err := p.ListingId(l.Key.Id)
+	if err != nil {
+		return l, false
+	}
+
+	l.Key = ListingKey{Portal: l.Key.Portal, Id: id}
+	versions := make([]ListingVersion, len(l.Versions))
+	for i, v := range l.Versions {
+		v.Property.ListingId = id
+		versions[i] = v
+	}
+	l.Versions = versions
+	return l, true
+}
+
+func sortListings(listings []StoredListing) {
+	sort.Slice(listings, func(i, j int) bool {
+		return listings[i].Key.String() < listings[j].Key.String()
+	})
+}
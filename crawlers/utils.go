@@ -1,12 +1,22 @@
 package crawlers
 
 import (
-	"encoding/csv"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
 )
 
+// Verbose turns on logging every request and every wait of the rate limits.
+var Verbose = false
+
+func verbosef(format string, args ...interface{}) {
+	if Verbose {
+		log.Printf(format, args...)
+	}
+}
+
 func JoinUri(a, b string) string {
 	if len(a) == 0 {
 		return b
@@ -21,23 +31,20 @@ func JoinUri(a, b string) string {
 	return a + "/" + b
 }
 
-func WritePropertiesToCsv(filepath string, props []PropertyInfo) {
-	var propsWritable [][]string
-	propsWritable = append(propsWritable, PropertyInfo{}.GetHeaders())
-	for _, prop := range props {
-		propsWritable = append(propsWritable, prop.ToSlice())
-	}
-
+func WritePropertiesToCsv(filepath string, props []PropertyInfo) error {
 	f, err := os.Create(filepath)
 	if err != nil {
-		log.Fatalf("could not create file '%s', can not save data", filepath)
+		return fmt.Errorf("could not create file '%s', can not save data: %s", filepath, err)
 	}
 	defer f.Close()
 
-	writer := csv.NewWriter(f)
-	err = writer.WriteAll(propsWritable)
-	if err != nil {
-		log.Fatalln("could not write csv ")
+	if err := WritePropertiesAsCsv(f, props); err != nil {
+		return fmt.Errorf("could not write csv '%s': %s", filepath, err)
 	}
+	return f.Close()
+}
 
+// WritePropertiesAsCsv writes the listing columns of the properties with the default settings.
+func WritePropertiesAsCsv(w io.Writer, props []PropertyInfo) error {
+	return writeCsv(w, CsvConfig{}, listingColumns(), GroupsOfListings(props))
 }
@@ -0,0 +1,240 @@
+package crawlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// PropertyTypes are the accepted values of "lakás_vagy_ház".
+var PropertyTypes = []string{"lakas", "haz"}
+
+// ValidationError is a single problem of the config, Field is the json path
+// of the offending value, e.g. "keresések[1].min_ár".
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors collects every problem found in a config.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = e.Error()
+	}
+	return fmt.Sprintf("invalid config, %d problem(s):\n  %s", len(errs), strings.Join(lines, "\n  "))
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) addf(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) nonNegative(field string, value float64) {
+	if value < 0 {
+		v.addf(field, "must not be negative, got %v", value)
+	}
+}
+
+// oneOf checks value against the allowed values, hinting at the intended one
+// for differences in case or whitespace.
+func (v *validator) oneOf(field, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSpace(value), a) {
+			v.addf(field, "unknown value '%s', did you mean '%s'?", value, a)
+			return
+		}
+	}
+	v.addf(field, "unknown value '%s', expected one of: %s", value, strings.Join(allowed, ", "))
+}
+
+// Validate checks the whole config and reports every problem at once as
+// ValidationErrors, or returns nil for a valid config.
+func (c Config) Validate() error {
+	v := &validator{}
+
+	if len(c.Searches) == 0 {
+		v.validateSearch("", c.Search)
+	} else {
+		names := make(map[string]int)
+		for i, s := range c.AllSearches() {
+			path := fmt.Sprintf("keresések[%d].", i)
+			v.validateSearch(path, s)
+			if j, ok := names[s.Name]; ok {
+				v.addf(path+"név", "'%s' is already used by keresések[%d]", s.Name, j)
+			}
+			names[s.Name] = i
+		}
+	}
+
+	v.nonNegative("http.időkorlát_mp", float64(c.Http.TimeoutSeconds))
+	if len(c.Http.FixtureMode) != 0 {
+		v.oneOf("http.felvétel_mód", c.Http.FixtureMode, []string{FixtureRecord, FixtureReplay})
+		if len(c.Http.FixturesDir) == 0 {
+			v.addf("http.felvételek_mappa", "must be given for felvétel_mód '%s'", c.Http.FixtureMode)
+		}
+	}
+	v.nonNegative("párhuzamos_letöltések", float64(c.Workers))
+	var limited []string
+	for name := range c.RateLimits {
+		limited = append(limited, name)
+	}
+	sort.Strings(limited)
+	for _, name := range limited {
+		rl := c.RateLimits[name]
+		path := fmt.Sprintf("sebességkorlátok.%s", name)
+		if _, err := GetPortal(name); err != nil {
+			v.addf(path, "%s, known portals: %s", err, strings.Join(PortalNames(), ", "))
+		}
+		v.nonNegative(path+".kérés_per_mp", rl.RequestsPerSecond)
+		v.nonNegative(path+".max_párhuzamos", float64(rl.MaxInFlight))
+	}
+
+	var relocated []string
+	for name := range c.BaseUrls {
+		relocated = append(relocated, name)
+	}
+	sort.Strings(relocated)
+	for _, name := range relocated {
+		path := fmt.Sprintf("portál_címek.%s", name)
+		if _, err := GetPortal(name); err != nil {
+			v.addf(path, "%s, known portals: %s", err, strings.Join(PortalNames(), ", "))
+		}
+		if u, err := url.Parse(c.BaseUrls[name]); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			v.addf(path, "'%s' is not an absolute http(s) url", c.BaseUrls[name])
+		}
+	}
+
+	if len(c.ExtractionRules) != 0 {
+		var ruleErrs ValidationErrors
+		if _, err := LoadExtractionRules(c.ExtractionRules); errors.As(err, &ruleErrs) {
+			for _, e := range ruleErrs {
+				v.addf("kinyerési_szabályok: "+e.Field, "%s", e.Message)
+			}
+		} else if err != nil {
+			v.addf("kinyerési_szabályok", "%s", err)
+		}
+	}
+
+	v.nonNegative("duplikáció_keresés.terület_tűrés_százalék", c.Matching.AreaTolerancePercent)
+	v.nonNegative("duplikáció_keresés.ár_tűrés_százalék", c.Matching.PriceTolerancePercent)
+	if c.Matching.MinScore < 0 || c.Matching.MinScore > 1 {
+		v.addf("duplikáció_keresés.min_pontszám", "must be between 0 and 1, got %v", c.Matching.MinScore)
+	}
+
+	v.validateCsv("csv.", c.Csv)
+	v.validateHealth("állapotfigyelés.", c.Health)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *validator) validateCsv(path string, cc CsvConfig) {
+	for i, key := range cc.Columns {
+		v.oneOf(fmt.Sprintf("%soszlopok[%d]", path, i), key, ColumnKeys())
+	}
+	if len(cc.Language) != 0 {
+		v.oneOf(path+"nyelv", cc.Language, []string{"hu", "en"})
+	}
+	if len(cc.Delimiter) != 0 && (utf8.RuneCountInString(cc.Delimiter) != 1 || strings.ContainsAny(cc.Delimiter, "\"\r\n")) {
+		v.addf(path+"elválasztó", "must be a single character other than a quote or a newline, got '%s'", cc.Delimiter)
+	}
+	if len(cc.DecimalSeparator) != 0 {
+		v.oneOf(path+"tizedesjel", cc.DecimalSeparator, []string{".", ","})
+	}
+}
+
+func (v *validator) validateSearch(path string, s Search) {
+	v.nonNegative(path+"min_ár", float64(s.MinPrice))
+	v.nonNegative(path+"max_ár", float64(s.MaxPrice))
+	v.nonNegative(path+"min_méret", float64(s.MinSize))
+	v.nonNegative(path+"max_méret", float64(s.MaxSize))
+	if s.MaxPrice > 0 && s.MinPrice > s.MaxPrice {
+		v.addf(path+"min_ár", "must not be greater than max_ár (%d > %d)", s.MinPrice, s.MaxPrice)
+	}
+	if s.MaxSize > 0 && s.MinSize > s.MaxSize {
+		v.addf(path+"min_méret", "must not be greater than max_méret (%d > %d)", s.MinSize, s.MaxSize)
+	}
+
+	v.oneOf(path+"lakás_vagy_ház", s.Type, PropertyTypes)
+	if len(s.Transaction) != 0 {
+		v.oneOf(path+"ügylet", string(s.Transaction), []string{string(Sale), string(Rent)})
+	}
+
+	for i, d := range s.Districts {
+		if _, err := ParseLocation(d); err != nil {
+			v.addf(fmt.Sprintf("%skerületek[%d]", path, i), "%s", err)
+		}
+	}
+	for i, l := range s.Locations {
+		if _, err := ParseLocation(l); err != nil {
+			v.addf(fmt.Sprintf("%shelyek[%d]", path, i), "%s", err)
+		}
+	}
+	for i, p := range s.Portals {
+		if _, err := GetPortal(p); err != nil {
+			v.addf(fmt.Sprintf("%sportálok[%d]", path, i), "%s, known portals: %s", err, strings.Join(PortalNames(), ", "))
+		}
+	}
+
+	for i, o := range s.Outputs {
+		v.oneOf(fmt.Sprintf("%skimenetek[%d]", path, i), o, OutputFormats())
+	}
+
+	v.validateFilter(path+"szűrők.", s.Filter)
+}
+
+func (v *validator) validateFilter(path string, fc FilterConfig) {
+	v.nonNegative(path+"min_telekterület", float64(fc.MinLotArea))
+	v.nonNegative(path+"min_szobák", float64(fc.MinRooms))
+	v.nonNegative(path+"építés_után", float64(fc.BuiltAfter))
+	v.nonNegative(path+"max_négyzetméter_ár", fc.MaxPricePerSqrMeter)
+
+	conditions := []string{string(ConditionNew), string(ConditionAsNew), string(ConditionRenovated), string(ConditionGood),
+		string(ConditionAverage), string(ConditionNeedsRenovation), string(ConditionUnderConstruction)}
+	for i, c := range fc.Conditions {
+		v.oneOf(fmt.Sprintf("%sállapotok[%d]", path, i), string(c), conditions)
+	}
+
+	heatings := []string{string(HeatingGasBoiler), string(HeatingGasConvector), string(HeatingDistrict), string(HeatingCentral),
+		string(HeatingElectric), string(HeatingHeatPump), string(HeatingSolidFuel), string(HeatingUnderfloor), string(HeatingOther)}
+	for i, h := range fc.Heatings {
+		v.oneOf(fmt.Sprintf("%sfűtések[%d]", path, i), string(h), heatings)
+	}
+}
+
+func (v *validator) validateHealth(path string, hc HealthConfig) {
+	var fields []string
+	for field := range hc.MinFillRates {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fieldPath := fmt.Sprintf("%smin_kitöltöttség_százalék.%s", path, field)
+		v.oneOf(fieldPath, field, HealthFieldKeys())
+		if rate := hc.MinFillRates[field]; rate < 0 || rate > 100 {
+			v.addf(fieldPath, "must be between 0 and 100, got %v", rate)
+		}
+	}
+	v.nonNegative(path+"max_visszaesés_százalék", hc.MaxDrop)
+	v.nonNegative(path+"min_hirdetések", float64(hc.MinListings))
+}
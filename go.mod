@@ -2,4 +2,9 @@ module github.com/PusztaiMate/ingatlan-crawler
 
 go 1.16
 
-require golang.org/x/net v0.0.0-20210716203947-853a461950ff
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/andybalholm/cascadia v1.3.1
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
+	gopkg.in/yaml.v3 v3.0.1
+)
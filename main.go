@@ -1,73 +1,71 @@
 package main
 
 import (
-	"log"
-	"sync"
-
-	"github.com/PusztaiMate/ingatlan-crawler/crawlers"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
-func main() {
-	config, err := crawlers.ReadJsonConfig("config.json")
-	if err != nil {
-		log.Fatalf("could not read config file, exiting: %s\n", err)
-	}
-	log.Printf("Config used: %#v", config)
-
-	dunaHouseUrl := crawlers.CreateDunaHouseQueryUrl(config)
-	dhle := crawlers.DunaHouseLinkCollector{}
-	dhlpe := crawlers.DunaHouseListingPagesExtractor{}
-	crawlers.CollectPropertyLinksForQuery(dunaHouseUrl, &dhle, &dhlpe)
-
-	ingatlanUrl := crawlers.CrateIngatlanQueryUrl(config)
-	ile := crawlers.IngatlanComLinkCollector{}
-	ilpe := crawlers.IngatlanComListingPagesExtractor{}
-	crawlers.CollectPropertyLinksForQuery(ingatlanUrl, &ile, &ilpe)
-
-	dunaHouseLinks := dhle.GetLinks()
-	log.Printf("Collected (%d) links from DunaHouse", len(dunaHouseLinks))
-	ingatlanLinks := ile.GetLinks()
-	log.Printf("Collected (%d) links from ingatlan.com", len(ingatlanLinks))
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
 
-	propInfos := make(chan crawlers.PropertyInfo, len(dunaHouseLinks)+len(ingatlanLinks))
-	var wg sync.WaitGroup
+var commands = []command{
+	{"crawl", "crawl the enabled portals and write the collected properties", runCrawlCommand},
+	{"export", "export previously collected properties", runExportCommand},
+	{"price-changes", "list the listings whose price changed", runPriceChangesCommand},
+	{"diff", "compare the results of two crawls", runDiffCommand},
+	{"validate-config", "check a config file without crawling", runValidateConfigCommand},
+	{"list-portals", "list the portals the crawler knows about", runListPortalsCommand},
+	{"extraction-rules", "print the built-in extraction rules, a starting point for a rules file", runExtractionRulesCommand},
+}
 
-	dhge := crawlers.DunaHouseGeneralInfoExtractor{}
-	dhme := crawlers.DunaHouseMainInfoExtractor{}
-	for _, l := range dunaHouseLinks {
-		linkToProp := crawlers.JoinUri(crawlers.DunaHouseBaseUrl, l)
-		wg.Add(1)
-		go func() {
-			crawlers.CollectInfoFromPropertyPage(linkToProp, propInfos, &dhge, &dhme)
-			defer wg.Done()
-		}()
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
 	}
-	imie := crawlers.IngatlanComMainInfoExtractor{}
-	ipie := crawlers.IngatlanComPropertyInfoExtractor{}
-	iae := crawlers.IngatlanComAddressExtractor{}
-	for _, l := range ingatlanLinks {
-		linkToProp := crawlers.JoinUri(crawlers.IngatlanBaseUrl, l)
-		wg.Add(1)
-		go func() {
-			crawlers.CollectInfoFromPropertyPage(linkToProp, propInfos, &imie, &ipie, &iae)
-			defer wg.Done()
-		}()
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printUsage()
+		return
 	}
 
-	log.Println("Waiting for crawlers to finish collecting info from individual pages.")
-	wg.Wait()
-	close(propInfos)
+	// The first signal cancels the context so the running command can save
+	// its work, a second one kills the process the usual way.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	var props []crawlers.PropertyInfo
-	for pi := range propInfos {
-		if !crawlers.IsPropPresentInList(props, pi) {
-			props = append(props, pi)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.name, err)
+			os.Exit(1)
 		}
+		return
 	}
-	log.Println("Finished waiting, starting processing data")
 
-	filename := crawlers.CreateFileNameFromConfig(config, "")
-	log.Printf("Collection finished, writing data to '%s'", filename)
-	crawlers.WritePropertiesToCsv(filename, props)
-	log.Println("Finished!")
+	fmt.Fprintf(os.Stderr, "unknown command: '%s'\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	prog := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", prog)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for the flags of a command\n", prog)
 }